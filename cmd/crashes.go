@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/denis-ismailaj/millwright/internal"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	last       int
+	crashesCmd = &cobra.Command{
+		Use:   "crashes <component>",
+		Short: "Shows the saved crashes of a component with the logs of the last ones.",
+		Args:  cobra.ExactArgs(1),
+		Run:   crashes,
+	}
+)
+
+func init() {
+	crashesCmd.Flags().StringVar(&crashDir, "crash-dir", internal.DefaultCrashDir(), "Directory the logs of crashed containers are saved to.")
+	crashesCmd.Flags().IntVarP(&last, "last", "n", 1, "Number of the most recent crashes to show the logs of.")
+	addConfigFlags(crashesCmd, "f")
+	RootCmd.AddCommand(crashesCmd)
+}
+
+func crashes(_ *cobra.Command, args []string) {
+	name := args[0]
+
+	project, err := currentProject()
+	if err != nil {
+		log.Fatal(err)
+	}
+	saved, err := internal.LoadCrashes(crashDir, project, name)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(saved) == 0 {
+		fmt.Printf("No crashes of %s have been saved.\n", name)
+		return
+	}
+
+	fmt.Printf("%d crashes of %s:\n", len(saved), name)
+	for _, crash := range saved {
+		fmt.Println("  " + crash.Summary())
+	}
+
+	// Show the logs of the most recent crashes, oldest first.
+	if last > len(saved) {
+		last = len(saved)
+	} else if last < 0 {
+		last = 0
+	}
+	for _, crash := range saved[len(saved)-last:] {
+		fmt.Printf("\n==> %s <==\n", crash.Summary())
+		for _, line := range crash.Logs {
+			fmt.Println(line)
+		}
+	}
+}
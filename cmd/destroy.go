@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"context"
+	"github.com/denis-ismailaj/millwright/internal"
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/client"
@@ -14,14 +15,15 @@ var (
 )
 
 func init() {
-	destroyCmd.Flags().StringVarP(&label, "label", "l", "used-by=millwright", "Label used for resources.")
+	destroyCmd.Flags().StringVarP(&label, "label", "l", "", "Label used for resources (defaults to the label of the project, used-by=millwright removes all projects).")
+	addConfigFlags(destroyCmd, "f")
 	RootCmd.AddCommand(destroyCmd)
 }
 
 var destroyCmd = &cobra.Command{
 	Use:   "destroy",
 	Args:  cobra.NoArgs,
-	Short: "Removes all resources created for this test task.",
+	Short: "Removes all resources created for a project.",
 	Run:   destroy,
 }
 
@@ -34,13 +36,20 @@ func destroy(*cobra.Command, []string) {
 
 	ctx := context.Background()
 
-	// Filter for the used-by label
+	// Only remove the resources of the project, unless another label is given.
+	if label == "" {
+		project, err := currentProject()
+		if err != nil {
+			log.Fatal(err)
+		}
+		label = internal.ProjectFilter(project)
+	}
 	labelFilter := filters.NewArgs(
 		filters.Arg("label", label),
 	)
 
-	// Find and remove containers
-	containers, err := cli.ContainerList(ctx, types.ContainerListOptions{Filters: labelFilter})
+	// Find and remove containers, including the exited ones that are kept for crash reports
+	containers, err := cli.ContainerList(ctx, types.ContainerListOptions{All: true, Filters: labelFilter})
 	if err != nil {
 		log.Fatal(err)
 	}
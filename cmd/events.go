@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"github.com/denis-ismailaj/millwright/internal"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+var eventsCmd = &cobra.Command{
+	Use:   "events",
+	Short: "Shows the status changes of the components of the running millwright as they happen.",
+	Args:  cobra.NoArgs,
+	Run:   events,
+}
+
+func init() {
+	addConfigFlags(eventsCmd, "f")
+	RootCmd.AddCommand(eventsCmd)
+}
+
+func events(*cobra.Command, []string) {
+	client := controlClient()
+
+	// Stop showing the events on interrupt.
+	ctx, cancelFn := context.WithCancel(context.Background())
+	go func() {
+		sigCh := make(chan os.Signal, 1)
+		signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+		<-sigCh
+		cancelFn()
+	}()
+
+	err := client.Events(ctx, func(transition internal.Transition) {
+		fmt.Printf(
+			"%s  %s: %s -> %s\n",
+			transition.At.Format(time.RFC3339), transition.Component, transition.From, transition.To,
+		)
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/denis-ismailaj/millwright/internal"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"os"
+	"path/filepath"
+)
+
+var (
+	importOutput string
+)
+
+func init() {
+	importComposeCmd.Flags().StringVarP(&importOutput, "output", "o", "", "File to write the config to (defaults to stdout).")
+	importCmd.AddCommand(importComposeCmd)
+	RootCmd.AddCommand(importCmd)
+}
+
+var importCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Converts configurations of other tools into a millwright config file.",
+	Args:  cobra.NoArgs,
+	Run:   root,
+}
+
+var importComposeCmd = &cobra.Command{
+	Use:   "compose <docker-compose.yml>",
+	Short: "Converts a docker-compose file into a millwright config file.",
+	Args:  cobra.ExactArgs(1),
+	Run:   importCompose,
+}
+
+func importCompose(_ *cobra.Command, args []string) {
+	// Relative build contexts are written relative to where the config will be saved.
+	outDir := "."
+	if importOutput != "" {
+		outDir = filepath.Dir(importOutput)
+	}
+
+	content, warnings, err := internal.ImportCompose(args[0], outDir)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for _, warning := range warnings {
+		log.Warn(warning)
+	}
+
+	if importOutput == "" {
+		fmt.Print(string(content))
+		return
+	}
+	if err := os.WriteFile(importOutput, content, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -4,8 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"github.com/docker/docker/api/types"
-	"github.com/docker/docker/api/types/filters"
+	"github.com/denis-ismailaj/millwright/internal"
 	"github.com/docker/docker/client"
 	"github.com/docker/go-connections/nat"
 	log "github.com/sirupsen/logrus"
@@ -20,6 +19,7 @@ var (
 
 func init() {
 	inspectCmd.Flags().IntVarP(&port, "port", "p", 8089, "Internal introspection port.")
+	addConfigFlags(inspectCmd, "f")
 	RootCmd.AddCommand(inspectCmd)
 }
 
@@ -41,20 +41,16 @@ func inspect(_ *cobra.Command, args []string) {
 
 	// Name of the component to inspect
 	name := args[0]
+	project, err := currentProject()
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	// Find the container for the component
-	list, err := cli.ContainerList(ctx, types.ContainerListOptions{
-		Filters: filters.NewArgs(
-			filters.Arg("name", name),
-		),
-	})
+	containerID, err := internal.FindComponent(ctx, project, name, false)
 	if err != nil {
 		log.Fatal(err)
 	}
-	if len(list) == 0 {
-		log.Fatalf("container for component %s not found", name)
-	}
-	containerID := list[0].ID
 
 	// Find the host port the internal introspection port is bound to.
 	inspect, err := cli.ContainerInspect(ctx, containerID)
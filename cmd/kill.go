@@ -2,14 +2,15 @@ package cmd
 
 import (
 	"context"
+	"github.com/denis-ismailaj/millwright/internal"
 	"github.com/docker/docker/api/types"
-	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/client"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
 
 func init() {
+	addConfigFlags(killCmd, "f")
 	RootCmd.AddCommand(killCmd)
 }
 
@@ -21,30 +22,34 @@ var killCmd = &cobra.Command{
 }
 
 func kill(_ *cobra.Command, args []string) {
-	// Create Docker client
-	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
-	if err != nil {
-		log.Fatal(err)
-	}
-
 	ctx := context.Background()
 
 	// The name of the component to kill
 	name := args[0]
+	project, err := currentProject()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// The running millwright, if there is one, keeps the component stopped instead of taking the kill for a crash.
+	if running, ok := internal.DialControl(project); ok {
+		if err := running.Kill(ctx, []string{name}); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
 
 	// Find the container for the component
-	list, err := cli.ContainerList(ctx, types.ContainerListOptions{
-		Filters: filters.NewArgs(
-			filters.Arg("name", name),
-		),
-	})
+	containerID, err := internal.FindComponent(ctx, project, name, false)
 	if err != nil {
 		log.Fatal(err)
 	}
-	if len(list) == 0 {
-		log.Fatalf("container for component %s not found", name)
+
+	// Create Docker client
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		log.Fatal(err)
 	}
-	containerID := list[0].ID
 
 	// Remove container forcibly
 	err = cli.ContainerRemove(ctx, containerID, types.ContainerRemoveOptions{Force: true})
@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"github.com/denis-ismailaj/millwright/internal"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+var (
+	follow  bool
+	tail    string
+	since   string
+	logsCmd = &cobra.Command{
+		Use:   "logs [component...]",
+		Short: "Shows the logs of components, interleaved by time.",
+		Run:   logs,
+	}
+)
+
+func init() {
+	// -f follows the logs like with docker, so the config file has no shorthand here.
+	addConfigFlags(logsCmd, "")
+	logsCmd.Flags().BoolVarP(&follow, "follow", "f", false, "Keep showing new lines as they're written.")
+	logsCmd.Flags().StringVar(&tail, "tail", "all", "Number of lines to show from the end of the logs of each component.")
+	logsCmd.Flags().StringVar(&since, "since", "", "Only show lines written after a timestamp (e.g. 2022-08-01T12:00:00Z) or since a duration ago (e.g. 10m).")
+	RootCmd.AddCommand(logsCmd)
+}
+
+func logs(_ *cobra.Command, args []string) {
+	options := internal.LogOptions{Follow: follow, Tail: -1}
+	if tail != "all" {
+		lines, err := strconv.Atoi(tail)
+		if err != nil || lines < 0 {
+			log.Fatalf("invalid --tail %s, expected a number of lines or all", tail)
+		}
+		options.Tail = lines
+	}
+	if since != "" {
+		sinceTime, err := parseSince(since)
+		if err != nil {
+			log.Fatal(err)
+		}
+		options.Since = sinceTime
+	}
+
+	project, err := loadProject()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Stop following the logs on interrupt.
+	ctx, cancelFn := context.WithCancel(context.Background())
+	go func() {
+		sigCh := make(chan os.Signal, 1)
+		signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+		<-sigCh
+		cancelFn()
+	}()
+
+	// Only color the output of terminals.
+	stat, err := os.Stdout.Stat()
+	color := err == nil && stat.Mode()&os.ModeCharDevice != 0
+
+	// Show the logs of all components if none are named.
+	err = internal.ShowLogs(ctx, project, args, options, color, os.Stdout)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// parseSince accepts both timestamps and durations that are relative to now, like `docker logs` does.
+func parseSince(value string) (time.Time, error) {
+	if duration, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-duration), nil
+	}
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid --since %s, expected a timestamp or a duration", value)
+}
@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"context"
+	"github.com/denis-ismailaj/millwright/internal"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	pauseCmd = &cobra.Command{
+		Use:   "pause",
+		Short: "Pauses monitoring, so that the running millwright doesn't restart failed components.",
+		Args:  cobra.NoArgs,
+		Run:   pause,
+	}
+	resumeCmd = &cobra.Command{
+		Use:   "resume",
+		Short: "Resumes monitoring after it was paused.",
+		Args:  cobra.NoArgs,
+		Run:   resume,
+	}
+)
+
+func init() {
+	addConfigFlags(pauseCmd, "f")
+	addConfigFlags(resumeCmd, "f")
+	RootCmd.AddCommand(pauseCmd)
+	RootCmd.AddCommand(resumeCmd)
+}
+
+func pause(*cobra.Command, []string) {
+	if err := controlClient().Pause(context.Background()); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func resume(*cobra.Command, []string) {
+	if err := controlClient().Resume(context.Background()); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// controlClient returns a client of the control API of the running millwright of the project,
+// for the commands that only make sense while one is running.
+func controlClient() *internal.ControlClient {
+	project, err := currentProject()
+	if err != nil {
+		log.Fatal(err)
+	}
+	client, ok := internal.DialControl(project)
+	if !ok {
+		log.Fatalf("no millwright is running for project %s", project)
+	}
+	return client
+}
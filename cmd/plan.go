@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/denis-ismailaj/millwright/internal"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"os"
+	"text/tabwriter"
+)
+
+var (
+	detailedExitCode bool
+	planCmd          = &cobra.Command{
+		Use:     "plan",
+		Aliases: []string{"diff"},
+		Short:   "Shows what start would change, without changing anything.",
+		Args:    cobra.NoArgs,
+		Run:     plan,
+	}
+)
+
+func init() {
+	addConfigFlags(planCmd, "f")
+	planCmd.Flags().BoolVar(&rebuild, "rebuild", false, "Plan as if images were built even if their build context hasn't changed.")
+	planCmd.Flags().StringVarP(&output, "output", "o", "table", "Output format, table or json.")
+	planCmd.Flags().BoolVar(&detailedExitCode, "detailed-exitcode", false, "Exit with 2 if start would change anything.")
+	RootCmd.AddCommand(planCmd)
+}
+
+func plan(*cobra.Command, []string) {
+	if output != "table" && output != "json" {
+		log.Fatalf("unknown output format %s, expected table or json", output)
+	}
+
+	project, err := loadProject()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	startPlan, err := internal.PlanStart(context.Background(), project, internal.Options{Rebuild: rebuild})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if output == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(startPlan); err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		printPlan(startPlan)
+	}
+
+	if detailedExitCode && startPlan.Changes() {
+		os.Exit(2)
+	}
+}
+
+// printPlan writes a plan as a table, in the order the components are started.
+func printPlan(startPlan *internal.Plan) {
+	if startPlan.CreateNetwork {
+		fmt.Printf("Network %s will be created.\n\n", startPlan.Network)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "COMPONENT\tACTION\tIMAGE\tREASON")
+	for _, action := range startPlan.Actions {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", action.Component, action.Action, orDash(action.Image), orDash(action.Reason))
+	}
+	w.Flush()
+
+	if !startPlan.Changes() {
+		fmt.Println("\nNo changes, start would reuse all the components as they are.")
+	}
+}
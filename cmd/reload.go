@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"strings"
+)
+
+var reloadCmd = &cobra.Command{
+	Use:   "reload",
+	Short: "Makes the running millwright reload its configuration and apply the changes, like SIGHUP does.",
+	Args:  cobra.NoArgs,
+	Run:   reload,
+}
+
+func init() {
+	// The running millwright reloads the file it was started with, the flags only tell which project it runs.
+	addConfigFlags(reloadCmd, "f")
+	RootCmd.AddCommand(reloadCmd)
+}
+
+func reload(*cobra.Command, []string) {
+	result, err := controlClient().Reload(context.Background())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if result.Empty() {
+		fmt.Println("The configuration hasn't changed.")
+		return
+	}
+	printNames("Added", result.Added)
+	printNames("Removed", result.Removed)
+	printNames("Changed", result.Changed)
+	printNames("Dependents of changed components", result.Dependents)
+}
+
+// printNames prints a labeled list of component names, unless it's empty.
+func printNames(label string, names []string) {
+	if len(names) > 0 {
+		fmt.Printf("%s: %s\n", label, strings.Join(names, ", "))
+	}
+}
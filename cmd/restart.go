@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"context"
+	"github.com/denis-ismailaj/millwright/internal"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var restartCmd = &cobra.Command{
+	Use:   "restart [component...]",
+	Short: "Restarts components in dependency order, waiting for each to be ready.",
+	Run:   restart,
+}
+
+func init() {
+	addConfigFlags(restartCmd, "f")
+	RootCmd.AddCommand(restartCmd)
+}
+
+func restart(_ *cobra.Command, args []string) {
+	project, err := loadProject()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Restart all components if none are named. The running millwright, if there is one, also notifies the
+	// dependents of the restarted components.
+	if client, ok := internal.DialControl(project.Name); ok {
+		err = client.Restart(context.Background(), args)
+	} else {
+		err = internal.RestartComponents(context.Background(), project, args)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
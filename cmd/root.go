@@ -1,10 +1,14 @@
 package cmd
 
 import (
+	"github.com/denis-ismailaj/millwright/internal"
 	"github.com/spf13/cobra"
 )
 
 var (
+	projectName string
+	configFile  string
+	composeFile string
 	// RootCmd is the root command for the internal.
 	RootCmd = &cobra.Command{
 		Use:   "internal",
@@ -13,6 +17,54 @@ var (
 	}
 )
 
+func init() {
+	RootCmd.PersistentFlags().StringVar(&projectName, "project", "", "Project name, which scopes the containers, images and network (defaults to the name of the config directory).")
+}
+
 func root(cmd *cobra.Command, _ []string) {
 	_ = cmd.Help()
 }
+
+// currentProject returns the project name given with --project, or the one derived from the config file given with the
+// flags like loadProject does, for the commands that don't need to load the config.
+func currentProject() (string, error) {
+	switch {
+	case projectName != "":
+		return projectName, internal.CheckProjectName(projectName)
+	case composeFile != "":
+		return internal.ConfigProjectName(composeFile), nil
+	default:
+		return internal.ConfigProjectName(configFile), nil
+	}
+}
+
+// addConfigFlags adds the flags that select the config of the project to a command.
+// The config file flag gets the given shorthand, unless it's empty.
+func addConfigFlags(cmd *cobra.Command, shorthand string) {
+	cmd.Flags().StringVarP(&configFile, "file", shorthand, "", "Project config file (defaults to the built-in configuration).")
+	cmd.Flags().StringVar(&composeFile, "compose", "", "Use a docker-compose file as the project config.")
+	cmd.MarkFlagsMutuallyExclusive("file", "compose")
+}
+
+// loadProject loads the project from the compose file or config file given with the flags,
+// or falls back to the built-in configuration. The project is renamed if a name is given with --project.
+func loadProject() (*internal.Project, error) {
+	var project *internal.Project
+	var err error
+	if composeFile != "" {
+		project, err = internal.LoadComposeConfiguration(composeFile)
+	} else {
+		project, err = internal.LoadConfiguration(configFile)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if projectName != "" {
+		if err := internal.CheckProjectName(projectName); err != nil {
+			return nil, err
+		}
+		project.Name = projectName
+	}
+	return project, nil
+}
@@ -9,12 +9,21 @@ import (
 	"os"
 	"os/signal"
 	"path"
+	"sync/atomic"
 	"syscall"
+	"time"
 )
 
 var (
-	force    bool
-	startCmd = &cobra.Command{
+	force          bool
+	rebuild        bool
+	interval       time.Duration
+	failureTimeout time.Duration
+	gracePeriod    time.Duration
+	parallelism    int
+	crashDir       string
+	crashLines     int
+	startCmd       = &cobra.Command{
 		Use:   "start",
 		Short: "Start the data processing pipeline and monitor it.",
 		Run:   start,
@@ -23,24 +32,50 @@ var (
 
 func init() {
 	startCmd.Flags().BoolVar(&force, "force", false, "Interrupt preceding millwrights.")
+	startCmd.Flags().BoolVar(&rebuild, "rebuild", false, "Build images even if their build context hasn't changed.")
+	addConfigFlags(startCmd, "f")
+	startCmd.Flags().IntVar(&parallelism, "parallelism", internal.DefaultParallelism, "Number of components that may be built and started at the same time.")
+	startCmd.Flags().DurationVar(&interval, "interval", 0, "Time between heartbeats (defaults to the project setting or 500ms).")
+	startCmd.Flags().DurationVar(&failureTimeout, "failure-timeout", 0, "Time without a successful heartbeat after which a component is restarted (defaults to the project setting or 3s).")
+	startCmd.Flags().DurationVar(&gracePeriod, "grace-period", 0, "Time after a component is started during which failed heartbeats are tolerated (defaults to the project setting or 0s).")
+	startCmd.Flags().StringVar(&crashDir, "crash-dir", internal.DefaultCrashDir(), "Directory the logs of crashed containers are saved to.")
+	startCmd.Flags().IntVar(&crashLines, "crash-lines", internal.DefaultCrashLogLines, "Lines of logs saved for each crash, -1 for all of them.")
 	RootCmd.AddCommand(startCmd)
 }
 
 func start(*cobra.Command, []string) {
+	// Load the configuration before waiting in line so that mistakes are reported right away.
+	project, err := loadProject()
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	// Create main context with cancellation
 	ctx, cancelFn := context.WithCancel(context.Background())
-	// Handle signals
+	// Set once this millwright is no longer waiting in line, so that SIGHUP doesn't reload a preceding one.
+	var inCharge int32
+	// Handle signals, SIGHUP reloads the configuration.
 	go func() {
 		sigCh := make(chan os.Signal, 1)
 		signal.Notify(sigCh, syscall.SIGHUP, syscall.SIGINT)
-		s := <-sigCh
-		log.Errorf("terminating due to signal %v", s)
-		cancelFn()
+		for s := range sigCh {
+			if s == syscall.SIGHUP {
+				if atomic.LoadInt32(&inCharge) == 0 {
+					log.Warn("Not reloading the configuration while waiting for preceding millwrights.")
+					continue
+				}
+				go reloadOnSignal(ctx, project.Name)
+				continue
+			}
+			log.Errorf("terminating due to signal %v", s)
+			cancelFn()
+			return
+		}
 	}()
 
-	// Create a coordinator instance
+	// Create a coordinator instance. Millwrights only wait for the ones of the same project.
 	coordinator := coordination.Coordinator{
-		Dir: path.Join(os.TempDir(), "millwright"),
+		Dir: path.Join(os.TempDir(), "millwright", project.Name),
 	}
 
 	// Create a wait file for this internal
@@ -77,8 +112,32 @@ func start(*cobra.Command, []string) {
 		return
 	default:
 	}
+	atomic.StoreInt32(&inCharge, 1)
 
 	// Start internal
-	log.Info("Starting internal.")
-	internal.StartMillwright(ctx)
+	log.Infof("Starting internal for project %s.", project.Name)
+	internal.StartMillwright(ctx, project, internal.Options{
+		Rebuild:     rebuild,
+		Parallelism: parallelism,
+		CrashDir:    crashDir,
+		CrashLines:  crashLines,
+		Timing: internal.Timing{
+			Interval:       interval,
+			FailureTimeout: failureTimeout,
+			GracePeriod:    gracePeriod,
+		},
+	}, loadProject)
+}
+
+// reloadOnSignal reloads the configuration of this millwright through its control API, which is served once its
+// components have been started.
+func reloadOnSignal(ctx context.Context, project string) {
+	client, ok := internal.DialControl(project)
+	if !ok {
+		log.Warn("Not reloading the configuration until the components have been started.")
+		return
+	}
+	if _, err := client.Reload(ctx); err != nil {
+		log.Errorf("Can't reload the configuration: %v", err)
+	}
 }
@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/denis-ismailaj/millwright/internal"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+var (
+	output    string
+	statusCmd = &cobra.Command{
+		Use:   "status",
+		Short: "Shows the state of every component.",
+		Args:  cobra.NoArgs,
+		Run:   status,
+	}
+)
+
+func init() {
+	addConfigFlags(statusCmd, "f")
+	statusCmd.Flags().StringVarP(&output, "output", "o", "table", "Output format, table or json.")
+	RootCmd.AddCommand(statusCmd)
+}
+
+func status(*cobra.Command, []string) {
+	if output != "table" && output != "json" {
+		log.Fatalf("unknown output format %s, expected table or json", output)
+	}
+
+	project, err := loadProject()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Ask the running millwright if there is one, since it knows what it's doing with the components.
+	var projectStatus *internal.ProjectStatus
+	if client, ok := internal.DialControl(project.Name); ok {
+		projectStatus, err = client.Status(context.Background())
+	} else {
+		projectStatus, err = internal.CheckStatus(context.Background(), project)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if output == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(projectStatus); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	printStatus(projectStatus)
+}
+
+// printStatus writes the status of a project as a table.
+// Components without a container and containers that aren't in the config are called out below it.
+func printStatus(projectStatus *internal.ProjectStatus) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "COMPONENT\tCONTAINER\tIMAGE\tSTATE\tUPTIME\tRESTARTS\tLAST HEARTBEAT\tPORT\tHEALTH")
+
+	var missing []string
+	for _, component := range projectStatus.Components {
+		name := component.Name
+		if component.Missing {
+			name = "! " + name
+			missing = append(missing, component.Name)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%d\t%s\t%s\t%s\n",
+			name, orDash(shortID(component.ContainerID)), orDash(component.Image), component.State,
+			orDash(component.Uptime), component.Restarts, formatTime(component.LastHeartbeat),
+			orDash(component.IntrospectionPort), component.Health,
+		)
+	}
+	w.Flush()
+
+	if len(missing) > 0 {
+		fmt.Printf("\n! Configured but without a container: %s\n", strings.Join(missing, ", "))
+	}
+
+	if len(projectStatus.Unknown) > 0 {
+		fmt.Println("\nContainers labeled by millwright that aren't in the config:")
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tCONTAINER\tIMAGE\tSTATE")
+		for _, container := range projectStatus.Unknown {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", container.Name, shortID(container.ContainerID), container.Image, container.State)
+		}
+		w.Flush()
+	}
+
+	switch {
+	case projectStatus.Paused:
+		fmt.Println("\nMonitoring is paused, failed components won't be restarted until `mw resume`.")
+	case !projectStatus.Monitored:
+		fmt.Println("\nNo millwright is running for this project, failed components won't be restarted.")
+	}
+}
+
+// shortID abbreviates a container ID like the Docker CLI does.
+func shortID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
+
+// formatTime formats an optional time for a table.
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.Format("15:04:05")
+}
+
+// orDash replaces empty table cells with a dash.
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"context"
+	"github.com/denis-ismailaj/millwright/internal"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var stopCmd = &cobra.Command{
+	Use:   "stop [component...]",
+	Short: "Stops components gracefully, dependents first, keeping their images and the network.",
+	Run:   stop,
+}
+
+func init() {
+	addConfigFlags(stopCmd, "f")
+	RootCmd.AddCommand(stopCmd)
+}
+
+func stop(_ *cobra.Command, args []string) {
+	project, err := loadProject()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Stop all components if none are named. The running millwright, if there is one, keeps them stopped.
+	if client, ok := internal.DialControl(project.Name); ok {
+		err = client.Stop(context.Background(), args)
+	} else {
+		err = internal.StopComponents(context.Background(), project, args)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
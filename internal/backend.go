@@ -4,164 +4,342 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"github.com/docker/docker/api/types"
-	"github.com/docker/docker/api/types/container"
-	"github.com/docker/docker/api/types/filters"
-	"github.com/docker/docker/api/types/network"
-	"github.com/docker/docker/pkg/archive"
-	"github.com/docker/go-connections/nat"
-	"io"
-	"os"
+	log "github.com/sirupsen/logrus"
+	"strconv"
+	"time"
 )
 
+// Backend is the container runtime that components are built and run with.
+type Backend interface {
+	// ListNetworks returns the IDs of the networks with the given name.
+	ListNetworks(ctx context.Context, name string) ([]string, error)
+	// CreateNetwork creates a bridge network and returns its ID.
+	CreateNetwork(ctx context.Context, name string, labels map[string]string) (string, error)
+
+	// BuildImage builds an image from a build context and tags it.
+	BuildImage(ctx context.Context, options BuildOptions) error
+	// PullImage pulls an image from its registry.
+	PullImage(ctx context.Context, ref string) error
+	// InspectImage returns a locally present image and an ok value.
+	InspectImage(ctx context.Context, ref string) (*Image, bool, error)
+
+	// CreateContainer creates a container and returns its ID.
+	CreateContainer(ctx context.Context, spec ContainerSpec) (string, error)
+	// StartContainer starts a created container.
+	StartContainer(ctx context.Context, id string) error
+	// RemoveContainer removes a container by force. It accepts both container IDs and names.
+	RemoveContainer(ctx context.Context, id string) error
+	// SignalContainer sends a signal, e.g. SIGHUP, to the main process of a running container.
+	SignalContainer(ctx context.Context, id string, signal string) error
+	// StopContainer sends a signal to the main process of a running container and waits for it to exit.
+	// If it doesn't exit within the grace period, it's killed. The stopped container is kept.
+	StopContainer(ctx context.Context, id string, signal string, grace time.Duration) error
+	// Exec runs a command inside a running container and returns its exit code.
+	Exec(ctx context.Context, id string, command []string) (int, error)
+	// Logs returns the last lines of the combined stdout and stderr of a container.
+	Logs(ctx context.Context, id string, lines int) (string, error)
+	// StreamLogs streams the lines of the combined stdout and stderr of a container that match the given options.
+	// The lines channel is closed once the logs end, or once the context is done when following them.
+	// If reading the logs fails, an error is sent before the lines channel is closed.
+	StreamLogs(ctx context.Context, id string, options LogOptions) (<-chan LogLine, <-chan error)
+
+	// InspectContainer returns the current state of a container.
+	InspectContainer(ctx context.Context, id string) (*Container, error)
+	// ListContainers returns the containers that match the given options.
+	ListContainers(ctx context.Context, options ListOptions) ([]*Container, error)
+	// Events streams the start, die, oom, kill and destroy events of the containers with the given label
+	// until the context is done. If the stream breaks, an error is sent and no more events follow.
+	Events(ctx context.Context, label string) (<-chan ContainerEvent, <-chan error)
+}
+
+// Labels that millwright adds to the resources it creates.
+const (
+	buildHashLabel  = "millwright.build-hash"  // hash of the build context an image was built from
+	restartsLabel   = "millwright.restarts"    // number of times the component of a container was restarted
+	configHashLabel = "millwright.config-hash" // hash of the settings a container was created with, see containerConfig
+	projectLabel    = "millwright.project"     // name of the project a resource belongs to
+	componentLabel  = "millwright.component"   // name of the component of a container
+)
+
+// BuildOptions specifies how an image is built.
+type BuildOptions struct {
+	ContextPath    string // absolute path
+	DockerfilePath string // relative to the build context
+	Tag            string
+	Labels         map[string]string
+}
+
+// Image is a locally present image as reported by the Backend.
+type Image struct {
+	ID     string
+	Labels map[string]string
+}
+
+// ContainerSpec specifies how a container is created.
+type ContainerSpec struct {
+	Name       string
+	Image      string
+	Env        []string // in KEY=VALUE format
+	Labels     map[string]string
+	Network    string   // name of the network to join
+	Aliases    []string // names the container can be reached by on the network, besides its own
+	Ports      []int    // TCP ports to bind to a random port on the host loopback interface
+	AutoRemove bool     // remove container when it exits, which loses its exit code and logs
+}
+
+// Container is the state of a container as reported by the Backend.
+type Container struct {
+	ID        string
+	Name      string
+	Image     string
+	Labels    map[string]string
+	Running   bool
+	StartedAt time.Time      // when the container was last started, zero if it never was
+	ExitCode  int            // exit code of the main process once the container has exited
+	OOMKilled bool           // whether the container was killed for running out of memory
+	Health    string         // status of the image HEALTHCHECK, empty if there is none
+	Networks  []string       // names of the networks the container is attached to
+	HostPorts map[int]string // container TCP port -> host port
+}
+
+// The container events that millwright reacts to.
+const (
+	EventStart   = "start"   // the container was started
+	EventDie     = "die"     // the main process of the container exited
+	EventOOM     = "oom"     // a process of the container ran out of memory
+	EventKill    = "kill"    // the container was sent a signal
+	EventDestroy = "destroy" // the container was removed
+)
+
+// ContainerEvent is a change in the lifecycle of a container as reported by the Backend.
+type ContainerEvent struct {
+	ContainerID string
+	Action      string // one of the Event constants
+	ExitCode    int    // exit code of die events
+	Signal      string // signal of kill events
+}
+
+// LogOptions selects the lines streamed by StreamLogs.
+type LogOptions struct {
+	Follow bool      // keep streaming new lines until the context is done
+	Tail   int       // number of lines from the end of the logs, all of them if negative
+	Since  time.Time // only lines written after this time, if set
+	Until  time.Time // only lines written before this time, if set
+}
+
+// LogLine is a line of output of a container.
+type LogLine struct {
+	Time time.Time // when the line was written
+	Text string
+}
+
+// ListOptions filters the containers returned by ListContainers.
+type ListOptions struct {
+	Name      string // matched exactly
+	Label     string // in KEY=VALUE format
+	Component string // name of the component the containers belong to, matched exactly by its label
+	All       bool   // include containers that aren't running
+}
+
 // getOrCreateNetwork checks if a network by the specified name exists or creates a new one.
 // It returns the network ID or an error.
 func (mw *Millwright) getOrCreateNetwork(ctx context.Context, name string) (string, error) {
-	list, err := mw.cli.NetworkList(ctx, types.NetworkListOptions{
-		Filters: filters.NewArgs(filters.Arg("name", name)),
-	})
+	list, err := mw.backend.ListNetworks(ctx, name)
 	if err != nil {
 		return "", err
 	}
 	if len(list) > 0 {
 		// Network already exists.
-		return list[0].ID, nil
+		return list[0], nil
 	}
 
-	net, err := mw.cli.NetworkCreate(ctx, name, types.NetworkCreate{
-		Driver: "bridge",
-		Labels: map[string]string{"used-by": ctx.Value(labelKey).(string)},
-	})
-	if err != nil {
-		return "", err
-	}
-	return net.ID, nil
+	return mw.backend.CreateNetwork(ctx, name, resourceLabels(ctx))
 }
 
-// getComponent tries to find an existing container with the name of the component.
+// getComponent tries to find the running container of the component in the project of the context.
 // It returns the container ID and an ok value.
 func (mw *Millwright) getComponent(ctx context.Context, component *Component) (string, bool) {
-	list, err := mw.cli.ContainerList(ctx, types.ContainerListOptions{
-		Filters: filters.NewArgs(filters.Arg("name", component.serviceName)),
-	})
+	list, err := mw.backend.ListContainers(ctx, ListOptions{Label: projectFilter(ctx), Component: component.serviceName})
 	if err != nil || len(list) == 0 {
 		return "", false
 	}
+	if len(list) > 1 {
+		// Only containers created by hand can share the labels, since the name of the container is unique.
+		log.Warnf("%d containers are labeled as %s, relaunching it.", len(list), component.serviceName)
+		return "", false
+	}
 
 	id := list[0].ID
-	component.containerID = id
-
-	// Update status
-	component.status = Running
+	component.setContainer(id)
+	if restarts, err := strconv.Atoi(list[0].Labels[restartsLabel]); err == nil {
+		component.adoptRestartCount(restarts)
+	}
 
 	return id, true
 }
 
-// launchComponent creates a new container representing the given component
-// and attaches to the network with the given name.
-// It returns the container ID or an error.
-func (mw *Millwright) launchComponent(ctx context.Context, component *Component) (string, error) {
-	// Create a tar of the build context folder.
-	tar, err := archive.TarWithOptions(component.runConfig.BuildContextPath, &archive.TarOptions{})
-	if err != nil {
-		return "", err
+// prepareImage makes sure the image of a component is available locally,
+// either by building it or by pulling it according to the component's pull policy.
+// It returns the image reference or an error.
+func (mw *Millwright) prepareImage(ctx context.Context, component *Component, buildHash string) (string, error) {
+	runConfig := component.runConfig
+
+	if runConfig.Image == "" {
+		return mw.buildImage(ctx, component, buildHash)
+	}
+
+	switch runConfig.PullPolicy {
+	case PullAlways:
+		if err := mw.backend.PullImage(ctx, runConfig.Image); err != nil {
+			return "", fmt.Errorf("can't pull image %s: %v", runConfig.Image, err)
+		}
+	case PullNever:
+		_, exists, err := mw.backend.InspectImage(ctx, runConfig.Image)
+		if err != nil {
+			return "", err
+		}
+		if !exists {
+			return "", fmt.Errorf("image %s is not present and the pull policy is %s", runConfig.Image, PullNever)
+		}
+	default:
+		_, exists, err := mw.backend.InspectImage(ctx, runConfig.Image)
+		if err != nil {
+			return "", err
+		}
+		if !exists {
+			if err := mw.backend.PullImage(ctx, runConfig.Image); err != nil {
+				return "", fmt.Errorf("can't pull image %s: %v", runConfig.Image, err)
+			}
+		}
+	}
+	return runConfig.Image, nil
+}
+
+// buildImage builds the image of a component, unless an image built from a build context with the same hash
+// already exists.
+// It returns the image reference or an error.
+func (mw *Millwright) buildImage(ctx context.Context, component *Component, hash string) (string, error) {
+	runConfig := component.runConfig
+	tag := scopedName(ctx, component)
+
+	if !mw.rebuild {
+		image, exists, err := mw.backend.InspectImage(ctx, tag)
+		if err == nil && exists && image.Labels[buildHashLabel] == hash {
+			log.Infof("Build context of %s hasn't changed, reusing image.", component.serviceName)
+			return tag, nil
+		}
 	}
 
 	// Build the component's image.
-	res, err := mw.cli.ImageBuild(ctx, tar, types.ImageBuildOptions{
-		Dockerfile:  component.runConfig.DockerfilePath,
-		PullParent:  true,
-		Remove:      true,
-		ForceRemove: true,
-		Tags:        []string{component.serviceName},
-		Labels:      map[string]string{"used-by": ctx.Value(labelKey).(string)},
+	labels := resourceLabels(ctx)
+	labels[buildHashLabel] = hash
+	err := mw.backend.BuildImage(ctx, BuildOptions{
+		ContextPath:    runConfig.BuildContextPath,
+		DockerfilePath: runConfig.DockerfilePath,
+		Tag:            tag,
+		Labels:         labels,
 	})
 	if err != nil {
 		return "", err
 	}
-	io.Copy(os.Stdout, res.Body)
-	defer res.Body.Close()
+	return tag, nil
+}
+
+// launchComponent creates a new container representing the given component
+// and attaches to the network with the given name. The container is labeled with the fingerprint of its settings,
+// and the image is built from a build context with the given hash, as computed by desiredConfig.
+// It returns the container ID or an error.
+func (mw *Millwright) launchComponent(
+	ctx context.Context, component *Component, config containerConfig, buildHash string,
+) (string, error) {
+	image, err := mw.prepareImage(ctx, component, buildHash)
+	if err != nil {
+		return "", err
+	}
+
+	labels := config.labels()
+	for key, value := range resourceLabels(ctx) {
+		labels[key] = value
+	}
+	labels[componentLabel] = component.serviceName
+	labels[restartsLabel] = strconv.Itoa(component.totalRestarts())
 
-	// Bind the introspection port of the container to the host.
-	exposedPorts, portBindings, _ := nat.ParsePortSpecs([]string{
-		fmt.Sprintf("127.0.0.1::%v", ctx.Value(introspectionPortKey)),
+	// Create the container, binding the introspection port of the container to the host
+	// and making it join the specified network when run, where the other components can reach it by its service name.
+	id, err := mw.backend.CreateContainer(ctx, ContainerSpec{
+		Name:    scopedName(ctx, component),
+		Image:   image,
+		Env:     component.runConfig.Env,
+		Labels:  labels,
+		Network: ctx.Value(networkNameKey).(string),
+		Aliases: []string{component.serviceName},
+		Ports:   component.boundPorts(ctx.Value(introspectionPortKey).(int)),
+		// Exited containers are kept so the crash can be reported, and removed when the component is relaunched.
+		AutoRemove: false,
 	})
-	hostConfig := &container.HostConfig{
-		AutoRemove:   true, // remove container when it exits
-		PortBindings: portBindings,
-	}
-
-	// Make the container join the specified network when run.
-	networkName := ctx.Value(networkNameKey).(string)
-	networkConfig := &network.NetworkingConfig{
-		EndpointsConfig: map[string]*network.EndpointSettings{networkName: {}},
-	}
-
-	// Create the container
-	containerConfig := &container.Config{
-		Image:        component.serviceName,
-		Env:          component.runConfig.Env,
-		ExposedPorts: exposedPorts,
-		Labels:       map[string]string{"used-by": ctx.Value(labelKey).(string)},
-	}
-	cont, err := mw.cli.ContainerCreate(
-		ctx,
-		containerConfig,
-		hostConfig,
-		networkConfig,
-		nil,
-		component.serviceName,
-	)
 	if err != nil {
 		return "", err
 	}
 
 	// Start the container
-	if err := mw.cli.ContainerStart(ctx, cont.ID, types.ContainerStartOptions{}); err != nil {
+	if err := mw.backend.StartContainer(ctx, id); err != nil {
 		return "", err
 	}
 
-	id := cont.ID
-	component.containerID = id
+	component.setContainer(id)
 
 	if !component.ignore {
-		// Find the component's introspection port and save it.
-		inspectPort, err := mw.getIntrospectionPort(ctx, id)
+		// Find the component's bound ports and save them.
+		hostPorts, err := mw.getHostPorts(ctx, id)
 		if err != nil {
 			return "", nil
 		}
-		component.inspectPort = inspectPort
+		component.setHostPorts(hostPorts)
 	}
 
 	return id, nil
 }
 
 // relaunchComponent removes the current container for a component if it exists, and then launches it.
+// Without a current container, a leftover container with the name of the component is removed instead.
 func (mw *Millwright) relaunchComponent(ctx context.Context, component *Component) (string, error) {
+	config, buildHash, err := mw.desiredConfig(ctx, component)
+	if err != nil {
+		return "", err
+	}
+	return mw.replaceContainer(ctx, component, config, buildHash)
+}
+
+// replaceContainer is relaunchComponent with the settings of the component already computed by desiredConfig.
+func (mw *Millwright) replaceContainer(
+	ctx context.Context, component *Component, config containerConfig, buildHash string,
+) (string, error) {
+	current, _ := component.container()
+	if current == "" {
+		current = scopedName(ctx, component)
+	}
+
 	// Ignoring error if no container currently exists.
 	// There's also the case that it may exist but for some reason couldn't be removed with force.
 	// That error is not handled here, but it will however present an error when we try to launch below.
-	_ = mw.cli.ContainerRemove(ctx, component.containerID, types.ContainerRemoveOptions{Force: true})
+	_ = mw.backend.RemoveContainer(ctx, current)
 
-	id, err := mw.launchComponent(ctx, component)
+	id, err := mw.launchComponent(ctx, component, config, buildHash)
 	if err != nil {
 		return "", err
 	}
 	return id, nil
 }
 
-// getIntrospectionPort finds the host port the container introspection port is bound to.
-// It returns the port or an error.
-func (mw *Millwright) getIntrospectionPort(ctx context.Context, containerID string) (string, error) {
-	inspect, err := mw.cli.ContainerInspect(ctx, containerID)
+// getHostPorts finds the host ports the container ports are bound to.
+// It returns the ports or an error if the introspection port isn't bound.
+func (mw *Millwright) getHostPorts(ctx context.Context, containerID string) (map[int]string, error) {
+	inspect, err := mw.backend.InspectContainer(ctx, containerID)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	internalPort := nat.Port(fmt.Sprintf("%v/tcp", ctx.Value(introspectionPortKey)))
-	ports := inspect.NetworkSettings.Ports[internalPort]
-	if len(ports) == 0 {
-		return "", errors.New("can't get introspection port")
+	if _, ok := inspect.HostPorts[ctx.Value(introspectionPortKey).(int)]; !ok {
+		return nil, errors.New("can't get introspection port")
 	}
-	introspectionPort := ports[0].HostPort
-	return introspectionPort, nil
+	return inspect.HostPorts, nil
 }
@@ -0,0 +1,128 @@
+package internal
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/docker/docker/pkg/fileutils"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// readDockerignore returns the exclude patterns in the .dockerignore file of a build context.
+// A missing file means nothing is excluded.
+func readDockerignore(contextPath string) ([]string, error) {
+	file, err := os.Open(filepath.Join(contextPath, ".dockerignore"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	// Patterns are cleaned the same way the Docker CLI does it.
+	var patterns []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		pattern := strings.TrimSpace(scanner.Text())
+		if pattern == "" || strings.HasPrefix(pattern, "#") {
+			continue
+		}
+		exclusion := strings.HasPrefix(pattern, "!")
+		if exclusion {
+			pattern = strings.TrimSpace(pattern[1:])
+		}
+		if pattern != "" {
+			pattern = filepath.Clean(pattern)
+			pattern = strings.TrimPrefix(filepath.ToSlash(pattern), "/")
+			if pattern == "" {
+				pattern = "."
+			}
+		}
+		if exclusion {
+			pattern = "!" + pattern
+		}
+		patterns = append(patterns, pattern)
+	}
+	return patterns, scanner.Err()
+}
+
+// hashBuildContext computes a hash of the content of a build context and its Dockerfile.
+// Files excluded by the .dockerignore of the context don't affect the hash, and neither do modification times.
+func hashBuildContext(contextPath string, dockerfilePath string) (string, error) {
+	patterns, err := readDockerignore(contextPath)
+	if err != nil {
+		return "", fmt.Errorf("can't read .dockerignore: %v", err)
+	}
+	matcher, err := fileutils.NewPatternMatcher(patterns)
+	if err != nil {
+		return "", fmt.Errorf("invalid .dockerignore: %v", err)
+	}
+
+	hash := sha256.New()
+
+	// The Dockerfile is always sent to the builder, even if it's ignored or outside the context.
+	dockerfile, err := os.ReadFile(filepath.Join(contextPath, dockerfilePath))
+	if err != nil {
+		return "", fmt.Errorf("can't read Dockerfile: %v", err)
+	}
+	fmt.Fprintf(hash, "dockerfile %s %d\x00", dockerfilePath, len(dockerfile))
+	hash.Write(dockerfile)
+
+	err = filepath.Walk(contextPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relative, err := filepath.Rel(contextPath, path)
+		if err != nil {
+			return err
+		}
+		if relative == "." {
+			return nil
+		}
+
+		excluded, err := matcher.Matches(relative)
+		if err != nil {
+			return err
+		}
+		if excluded {
+			// A directory can only be skipped if no exception could include something inside it.
+			if info.IsDir() && !matcher.Exclusions() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		relative = filepath.ToSlash(relative)
+		switch {
+		case info.Mode()&os.ModeSymlink != 0:
+			target, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(hash, "symlink %s %s\x00", relative, target)
+		case info.IsDir():
+			fmt.Fprintf(hash, "dir %s %o\x00", relative, info.Mode().Perm())
+		case info.Mode().IsRegular():
+			fmt.Fprintf(hash, "file %s %o %d\x00", relative, info.Mode().Perm(), info.Size())
+			file, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			if _, err := io.Copy(hash, file); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("can't hash build context: %v", err)
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,70 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestHashBuildContext(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	hash := func() string {
+		t.Helper()
+		h, err := hashBuildContext(dir, "build/Dockerfile")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return h
+	}
+
+	write("build/Dockerfile", "FROM scratch\n")
+	write("main.go", "package main\n")
+	write(".dockerignore", "# comment\nlogs\n*.tmp\n!keep.tmp\n")
+	initial := hash()
+
+	if hash() != initial {
+		t.Fatal("Hash is not deterministic.")
+	}
+
+	// Ignored files don't change the hash.
+	write("logs/output.log", "noise")
+	write("scratch.tmp", "noise")
+	if hash() != initial {
+		t.Error("Ignored files changed the hash.")
+	}
+
+	// Exceptions to ignored patterns do.
+	write("keep.tmp", "kept")
+	withException := hash()
+	if withException == initial {
+		t.Error("File excluded from .dockerignore patterns didn't change the hash.")
+	}
+
+	write("main.go", "package main\n\nfunc main() {}\n")
+	withSource := hash()
+	if withSource == withException {
+		t.Error("Changed source file didn't change the hash.")
+	}
+
+	write("build/Dockerfile", "FROM alpine\n")
+	if hash() == withSource {
+		t.Error("Changed Dockerfile didn't change the hash.")
+	}
+
+	// Touching a file without changing its content doesn't change the hash.
+	unchanged := hash()
+	write("main.go", "package main\n\nfunc main() {}\n")
+	if hash() != unchanged {
+		t.Error("Rewriting a file with the same content changed the hash.")
+	}
+}
@@ -1,33 +1,398 @@
 package internal
 
-import "time"
+import (
+	"fmt"
+	"sync"
+	"time"
+)
 
 // Component represents a component that the internal is in charge of running.
 type Component struct {
 	// Config variables
-	serviceName  string // serves as both container and DNS name
-	runConfig    RunConfiguration
-	dependencies []*Component
-	ignore       bool // don't check health
-	// Runtime variables
+	serviceName    string // serves as both container and DNS name
+	runConfig      RunConfiguration
+	dependencies   []*Component
+	onRestart      map[*Component]DependencyPolicy // how it reacts to restarts of its dependencies, none by default
+	ignore         bool                            // don't check health
+	probe          *Probe                          // how health is checked, defaults to an HTTP GET of the introspection endpoint
+	readinessProbe *Probe                          // how readiness to serve is checked, defaults to probe
+	startupTimeout time.Duration                   // time the component may take to become ready after it's started
+	timing         Timing                          // how often it's checked, defaults to the timing of the project
+	// Runtime variables, guarded by mu since they're shared by the reconciler and the goroutines handling failures
+	mu                      sync.Mutex
 	containerID             string
-	inspectPort             string // the host port the container introspection port is bound to
+	hostPorts               map[int]string // container TCP port -> host port it is bound to, never modified in place
 	status                  status
+	startedAt               time.Time
+	liveness                probeState
+	readiness               probeState
 	lastSuccessfulHeartbeat time.Time
+	restarts                []time.Time     // times of the restarts within crashLoopWindow
+	restartCount            int             // total number of restarts
+	exitReason              string          // why the current container stopped, from its events
+	checking                bool            // queued for or being checked by a heartbeat worker
+	fingerprint             containerConfig // settings its container should be created with, once known
+	buildHash               string          // hash of the build context the fingerprint was computed with
+	started                 *Container      // container last seen starting as the component, from the container events
+}
+
+// defaultStartupTimeout is the startup timeout of components that don't specify one.
+const defaultStartupTimeout = time.Minute
+
+// detached returns a copy of the configuration of the component without its runtime state, which can be probed
+// without affecting the component.
+func (c *Component) detached() *Component {
+	return &Component{
+		serviceName:    c.serviceName,
+		runConfig:      c.runConfig,
+		dependencies:   c.dependencies,
+		onRestart:      c.onRestart,
+		ignore:         c.ignore,
+		probe:          c.probe,
+		readinessProbe: c.readinessProbe,
+		startupTimeout: c.startupTimeout,
+		timing:         c.timing,
+	}
+}
+
+// livenessProbe returns the probe used to check the health of the component, with defaults applied.
+// Probes without a period run at the given interval.
+func (c *Component) livenessProbe(introspectionPort int, interval time.Duration) Probe {
+	var probe Probe
+	if c.probe != nil {
+		probe = *c.probe
+	}
+	return probe.withDefaults(introspectionPort, interval)
+}
+
+// readyProbe returns the probe used to check whether the component is ready to serve, with defaults applied.
+// Probes without a period run at the given interval.
+func (c *Component) readyProbe(introspectionPort int, interval time.Duration) Probe {
+	if c.readinessProbe == nil {
+		return c.livenessProbe(introspectionPort, interval)
+	}
+	return c.readinessProbe.withDefaults(introspectionPort, interval)
+}
+
+// dependencyPolicy returns how the component reacts when the given dependency is restarted.
+func (c *Component) dependencyPolicy(dependency *Component) DependencyPolicy {
+	if policy, ok := c.onRestart[dependency]; ok {
+		return policy
+	}
+	return DependencyNone
+}
+
+// currentStatus returns the status of the component.
+func (c *Component) currentStatus() status {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.status
+}
+
+// container returns the ID of the current container of the component and the host ports it is bound to.
+func (c *Component) container() (string, map[int]string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.containerID, c.hostPorts
+}
+
+// setContainer records that the component is running in a newly found or launched container.
+func (c *Component) setContainer(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.containerID = id
+	c.startedAt = time.Now()
+	c.exitReason = ""
+}
+
+// setHostPorts records the host ports the container of the component is bound to.
+func (c *Component) setHostPorts(hostPorts map[int]string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.hostPorts = hostPorts
+}
+
+// livenessDue checks whether the liveness probe should run again.
+func (c *Component) livenessDue(probe Probe) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.liveness.due(probe)
+}
+
+// recordLiveness adds the result of a liveness check to the state of the component.
+// It returns the number of consecutive failures and since when the component has been unresponsive,
+// which is the time of the last successful check, or the end of the grace period after it was started if that's later.
+func (c *Component) recordLiveness(ok bool, probe Probe, gracePeriod time.Duration) (int, time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.liveness.record(ok, probe)
+	if ok {
+		c.lastSuccessfulHeartbeat = time.Now()
+	}
+	since := c.lastSuccessfulHeartbeat
+	if graceEnd := c.startedAt.Add(gracePeriod); graceEnd.After(since) {
+		since = graceEnd
+	}
+	return c.liveness.failures, since
+}
+
+// readinessDue checks whether the readiness probe should run again.
+func (c *Component) readinessDue(probe Probe) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.readiness.due(probe)
+}
+
+// recordReadiness adds the result of a readiness check to the state of the component.
+// It returns whether the component is ready.
+func (c *Component) recordReadiness(ok bool, probe Probe) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.readiness.record(ok, probe)
+	return c.readiness.healthy
+}
+
+// resetProbes forgets the results of previous checks, e.g. before the component is relaunched.
+func (c *Component) resetProbes() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.liveness = probeState{}
+	c.readiness = probeState{}
+}
+
+// beginCheck marks the component as being checked.
+// It returns false if it's already being checked, so that a slow check isn't queued again.
+func (c *Component) beginCheck() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.checking {
+		return false
+	}
+	c.checking = true
+	return true
+}
+
+// endCheck marks the check of the component as finished.
+func (c *Component) endCheck() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.checking = false
+}
+
+// startupDeadline returns the time by which the component should become ready.
+func (c *Component) startupDeadline() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.startedAt.Add(c.timeToStart())
+}
+
+// timeToStart returns the time the component may take to become ready after it's started.
+func (c *Component) timeToStart() time.Duration {
+	if c.startupTimeout == 0 {
+		return defaultStartupTimeout
+	}
+	return c.startupTimeout
+}
+
+// recordEvent updates why the current container of the component stopped with one of its events.
+// It returns the reason.
+func (c *Component) recordEvent(event ContainerEvent) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch event.Action {
+	case EventOOM:
+		c.exitReason = "ran out of memory"
+	case EventKill:
+		c.exitReason = fmt.Sprintf("killed with signal %s", event.Signal)
+	case EventDie:
+		if c.exitReason != "" {
+			c.exitReason += ", "
+		}
+		c.exitReason += fmt.Sprintf("exited with code %d", event.ExitCode)
+	case EventDestroy:
+		if c.exitReason == "" {
+			c.exitReason = "removed"
+		}
+	}
+	return c.exitReason
+}
+
+// lastExitReason returns why the current container of the component stopped, if it's known.
+func (c *Component) lastExitReason() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.exitReason
+}
+
+// recordRestart counts a restart of the component and forgets the restarts that are outside of crashLoopWindow.
+// It returns the number of restarts within the window, including this one.
+func (c *Component) recordRestart(now time.Time) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	recent := c.restarts[:0]
+	for _, restart := range c.restarts {
+		if now.Sub(restart) < crashLoopWindow {
+			recent = append(recent, restart)
+		}
+	}
+	c.restarts = append(recent, now)
+	c.restartCount++
+	return len(c.restarts)
+}
+
+// totalRestarts returns the number of times the component was restarted.
+func (c *Component) totalRestarts() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.restartCount
+}
+
+// adoptRestartCount takes over the number of restarts recorded by the millwright that launched the container
+// of the component, unless more restarts are already known.
+func (c *Component) adoptRestartCount(restarts int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if restarts > c.restartCount {
+		c.restartCount = restarts
+	}
+}
+
+// setFingerprint records the settings the container of the component should be created with,
+// and the hash of the build context they were computed with.
+func (c *Component) setFingerprint(config containerConfig, buildHash string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.fingerprint = config
+	c.buildHash = buildHash
+}
+
+// currentFingerprint returns the settings the container of the component should be created with,
+// nil if they aren't known yet, and the hash of the build context they were computed with.
+func (c *Component) currentFingerprint() (containerConfig, string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.fingerprint, c.buildHash
+}
+
+// recordStart records a container that was started as the component.
+func (c *Component) recordStart(container *Container) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.started = container
+}
+
+// lastStarted returns the container last seen starting as the component, nil if none was.
+func (c *Component) lastStarted() *Container {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.started
+}
+
+// lastHeartbeat returns the time of the last successful heartbeat of the component, zero if there was none.
+func (c *Component) lastHeartbeat() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.lastSuccessfulHeartbeat
+}
+
+// boundPorts returns the container ports of the component that need to be bound to the host.
+func (c *Component) boundPorts(introspectionPort int) []int {
+	ports := []int{introspectionPort}
+	bound := map[int]bool{introspectionPort: true}
+	// Only the ports of the probes matter here, not their period.
+	for _, probe := range []Probe{c.livenessProbe(introspectionPort, 0), c.readyProbe(introspectionPort, 0)} {
+		if probe.Port != 0 && !bound[probe.Port] {
+			ports = append(ports, probe.Port)
+			bound[probe.Port] = true
+		}
+	}
+	return ports
+}
+
+// Timing specifies how often a component is checked and how long it may fail its checks before it's restarted.
+// Zero values are replaced by the timing of the project, or by defaultTiming.
+type Timing struct {
+	Interval       time.Duration // time between heartbeats, and the default period of probes
+	FailureTimeout time.Duration // time without a successful heartbeat after which a failing component is restarted
+	GracePeriod    time.Duration // time after a start during which failing heartbeats are tolerated
+}
+
+// defaultTiming is used when neither the component nor the project specify a timing.
+var defaultTiming = Timing{
+	Interval:       500 * time.Millisecond,
+	FailureTimeout: 3 * time.Second,
+}
+
+// withDefaults returns a copy of the timing with zero values replaced by the given defaults.
+func (t Timing) withDefaults(defaults Timing) Timing {
+	if t.Interval == 0 {
+		t.Interval = defaults.Interval
+	}
+	if t.FailureTimeout == 0 {
+		t.FailureTimeout = defaults.FailureTimeout
+	}
+	if t.GracePeriod == 0 {
+		t.GracePeriod = defaults.GracePeriod
+	}
+	return t
 }
 
 // RunConfiguration specifies how a Component can be run.
+// A component is either built from a Dockerfile or run from a prebuilt Image.
 type RunConfiguration struct {
-	DockerfilePath   string   // relative to build context
-	BuildContextPath string   // absolute path
-	Env              []string // in KEY=VALUE format
+	DockerfilePath   string        // relative to build context
+	BuildContextPath string        // absolute path
+	Image            string        // image reference with an optional tag or digest
+	PullPolicy       PullPolicy    // when to pull Image, defaults to PullIfNotPresent
+	Env              []string      // in KEY=VALUE format
+	StopSignal       string        // signal that asks the component to stop, defaults to SIGTERM
+	StopGracePeriod  time.Duration // time to exit after the stop signal before being killed, defaults to 10s
 }
 
-type status int32
+// Defaults of the stop settings of a RunConfiguration.
+const (
+	defaultStopSignal      = "SIGTERM"
+	defaultStopGracePeriod = 10 * time.Second
+)
+
+// DependencyPolicy specifies how a component reacts when one of its dependencies is restarted.
+type DependencyPolicy string
+
+// The supported dependency policies.
+const (
+	DependencyNone    DependencyPolicy = "none"    // keep running as if nothing happened
+	DependencyRestart DependencyPolicy = "restart" // restart once the dependency is ready again
+	DependencySignal  DependencyPolicy = "signal"  // send SIGHUP once the dependency is ready again, e.g. to reconnect
+)
+
+// PullPolicy specifies when the image of a component is pulled from its registry.
+type PullPolicy string
 
-// The statuses the Component may be in.
+// The supported pull policies.
 const (
-	Unstarted status = iota
-	Running
-	Failed
+	PullAlways       PullPolicy = "always"
+	PullIfNotPresent PullPolicy = "if-not-present"
+	PullNever        PullPolicy = "never"
 )
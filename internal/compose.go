@@ -0,0 +1,378 @@
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// composeIgnoredKeys are top-level compose keys that carry no meaning for millwright
+// and can be dropped without a warning.
+var composeIgnoredKeys = map[string]bool{
+	"version": true,
+}
+
+// composePullPolicies maps the compose pull policies to the ones millwright supports.
+var composePullPolicies = map[string]PullPolicy{
+	"always":         PullAlways,
+	"missing":        PullIfNotPresent,
+	"if_not_present": PullIfNotPresent,
+	"never":          PullNever,
+}
+
+// composeImport accumulates the result of converting a docker-compose file.
+type composeImport struct {
+	dir      string // directory of the compose file
+	file     configFile
+	warnings []string
+}
+
+// warnf records a warning about something in the compose file that millwright can't model.
+func (c *composeImport) warnf(format string, args ...interface{}) {
+	c.warnings = append(c.warnings, fmt.Sprintf(format, args...))
+}
+
+// loadComposeFile reads a docker-compose file and converts it into a millwright config file.
+// Build contexts in the result are absolute.
+// It returns the config file, a list of warnings for the compose keys that were not imported, or an error.
+func loadComposeFile(path string) (*configFile, []string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("can't read compose file: %v", err)
+	}
+
+	var document map[string]yaml.Node
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, nil, fmt.Errorf("can't parse compose file %s: %v", path, err)
+	}
+
+	dir, err := filepath.Abs(filepath.Dir(path))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	c := &composeImport{
+		dir:  dir,
+		file: configFile{Services: map[string]serviceConfig{}},
+	}
+
+	for _, key := range sortedKeys(document) {
+		if key == "services" || composeIgnoredKeys[key] {
+			continue
+		}
+		c.warnf("top-level key %s is not supported", key)
+	}
+
+	servicesNode, ok := document["services"]
+	if !ok {
+		return nil, nil, fmt.Errorf("compose file %s has no services", path)
+	}
+	var services map[string]map[string]yaml.Node
+	if err := servicesNode.Decode(&services); err != nil {
+		return nil, nil, fmt.Errorf("can't parse services in %s: %v", path, err)
+	}
+
+	for _, name := range sortedKeys(services) {
+		service, err := c.convertService(name, services[name])
+		if err != nil {
+			return nil, nil, err
+		}
+		c.file.Services[name] = service
+	}
+
+	return &c.file, c.warnings, nil
+}
+
+// convertService maps the keys of a compose service to a service config.
+func (c *composeImport) convertService(name string, keys map[string]yaml.Node) (serviceConfig, error) {
+	var service serviceConfig
+
+	for _, key := range sortedKeys(keys) {
+		node := keys[key]
+		var err error
+		switch key {
+		case "build":
+			err = c.convertBuild(name, &node, &service)
+		case "image":
+			err = node.Decode(&service.Image)
+		case "pull_policy":
+			var policy string
+			err = node.Decode(&policy)
+			if pullPolicy, ok := composePullPolicies[policy]; ok {
+				service.PullPolicy = string(pullPolicy)
+			} else if err == nil {
+				c.warnf("service %s: pull_policy %s is not supported", name, policy)
+			}
+		case "environment":
+			err = c.convertEnvironment(&node, &service)
+		case "depends_on":
+			err = c.convertDependsOn(name, &node, &service)
+		case "healthcheck":
+			service.Probe, err = c.convertHealthcheck(name, &node)
+		case "stop_signal":
+			err = node.Decode(&service.StopSignal)
+		case "stop_grace_period":
+			err = node.Decode(&service.StopGrace)
+		default:
+			c.warnf("service %s: key %s is not supported", name, key)
+		}
+		if err != nil {
+			return serviceConfig{}, fmt.Errorf("service %s: invalid %s: %v", name, key, err)
+		}
+	}
+
+	switch {
+	case service.Context == "" && service.Image == "":
+		return serviceConfig{}, fmt.Errorf("service %s: has neither a build section nor an image", name)
+	case service.Context != "" && service.Image != "":
+		// Compose uses the image as the tag of the built image, millwright tags images with the service name.
+		c.warnf("service %s: image %s is ignored because the service is built", name, service.Image)
+		service.Image = ""
+		service.PullPolicy = ""
+	case service.Image == "" && service.PullPolicy != "":
+		c.warnf("service %s: pull_policy is ignored because the service is built", name)
+		service.PullPolicy = ""
+	}
+
+	return service, nil
+}
+
+// convertBuild handles both the short (context path only) and long forms of the build key.
+func (c *composeImport) convertBuild(name string, node *yaml.Node, service *serviceConfig) error {
+	var buildContext string
+	switch node.Kind {
+	case yaml.ScalarNode:
+		buildContext = node.Value
+	case yaml.MappingNode:
+		var build map[string]yaml.Node
+		if err := node.Decode(&build); err != nil {
+			return err
+		}
+		for _, key := range sortedKeys(build) {
+			value := build[key]
+			switch key {
+			case "context":
+				if err := value.Decode(&buildContext); err != nil {
+					return err
+				}
+			case "dockerfile":
+				if err := value.Decode(&service.Dockerfile); err != nil {
+					return err
+				}
+			default:
+				c.warnf("service %s: key build.%s is not supported", name, key)
+			}
+		}
+	default:
+		return fmt.Errorf("expected a string or a mapping")
+	}
+
+	if buildContext == "" {
+		buildContext = "."
+	}
+	if !filepath.IsAbs(buildContext) {
+		buildContext = filepath.Join(c.dir, buildContext)
+	}
+	service.Context = buildContext
+	return nil
+}
+
+// convertEnvironment handles both the list and mapping forms of the environment key.
+// Variables without a value are taken from the environment when the configuration is loaded, like compose does, so
+// that imported files don't contain values of the current environment.
+func (c *composeImport) convertEnvironment(node *yaml.Node, service *serviceConfig) error {
+	switch node.Kind {
+	case yaml.SequenceNode:
+		var list []string
+		if err := node.Decode(&list); err != nil {
+			return err
+		}
+		for _, entry := range list {
+			if strings.Contains(entry, "=") {
+				service.Env = append(service.Env, entry)
+				continue
+			}
+			service.HostEnv = append(service.HostEnv, entry)
+		}
+	case yaml.MappingNode:
+		var mapping map[string]*string
+		if err := node.Decode(&mapping); err != nil {
+			return err
+		}
+		for _, key := range sortedKeys(mapping) {
+			if mapping[key] == nil {
+				service.HostEnv = append(service.HostEnv, key)
+				continue
+			}
+			service.Env = append(service.Env, fmt.Sprintf("%s=%s", key, *mapping[key]))
+		}
+	default:
+		return fmt.Errorf("expected a list or a mapping")
+	}
+	return nil
+}
+
+// convertDependsOn handles both the list and mapping forms of the depends_on key.
+// Dependencies with restart set in the mapping form get the restart policy.
+func (c *composeImport) convertDependsOn(name string, node *yaml.Node, service *serviceConfig) error {
+	switch node.Kind {
+	case yaml.SequenceNode:
+		return node.Decode(&service.Dependencies)
+	case yaml.MappingNode:
+		var mapping map[string]map[string]yaml.Node
+		if err := node.Decode(&mapping); err != nil {
+			return err
+		}
+		for _, dependency := range sortedKeys(mapping) {
+			for _, key := range sortedKeys(mapping[dependency]) {
+				value := mapping[dependency][key]
+				switch {
+				case key == "condition" && (value.Value == "service_started" || value.Value == "service_healthy"):
+					// Dependents are always launched once their dependencies are ready,
+					// which satisfies both of these conditions.
+				case key == "restart" && value.Value == "true":
+					if service.OnRestart == nil {
+						service.OnRestart = map[string]string{}
+					}
+					service.OnRestart[dependency] = string(DependencyRestart)
+				case key == "restart" && value.Value == "false":
+					// Dependents aren't notified of restarts by default.
+				default:
+					c.warnf("service %s: depends_on.%s.%s: %s is not supported", name, dependency, key, value.Value)
+				}
+			}
+			service.Dependencies = append(service.Dependencies, dependency)
+		}
+		return nil
+	default:
+		return fmt.Errorf("expected a list or a mapping")
+	}
+}
+
+// convertHealthcheck maps a compose healthcheck to an exec probe that runs the same test.
+func (c *composeImport) convertHealthcheck(name string, node *yaml.Node) (*probeConfig, error) {
+	var healthcheck map[string]yaml.Node
+	if err := node.Decode(&healthcheck); err != nil {
+		return nil, err
+	}
+
+	probe := &probeConfig{Type: string(ExecProbe)}
+	for _, key := range sortedKeys(healthcheck) {
+		value := healthcheck[key]
+		var err error
+		switch key {
+		case "test":
+			probe.Command, err = convertHealthcheckTest(&value)
+		case "interval":
+			err = value.Decode(&probe.Period)
+		case "timeout":
+			err = value.Decode(&probe.Timeout)
+		case "retries":
+			err = value.Decode(&probe.FailureThreshold)
+		case "disable":
+			var disable bool
+			err = value.Decode(&disable)
+			if err == nil && disable {
+				// Millwright always checks health unless the component is ignored.
+				c.warnf("service %s: disabled healthcheck is not supported, using the default probe", name)
+				return nil, nil
+			}
+		default:
+			c.warnf("service %s: key healthcheck.%s is not supported", name, key)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("healthcheck.%s: %v", key, err)
+		}
+	}
+
+	if probe.Command == nil {
+		c.warnf("service %s: healthcheck without a test is not supported, using the default probe", name)
+		return nil, nil
+	}
+	return probe, nil
+}
+
+// convertHealthcheckTest converts the test of a compose healthcheck into a command.
+// Tests can be a shell command string, or a list starting with CMD or CMD-SHELL.
+func convertHealthcheckTest(node *yaml.Node) ([]string, error) {
+	if node.Kind == yaml.ScalarNode {
+		return []string{"/bin/sh", "-c", node.Value}, nil
+	}
+
+	var test []string
+	if err := node.Decode(&test); err != nil {
+		return nil, err
+	}
+	if len(test) < 2 {
+		return nil, fmt.Errorf("expected CMD or CMD-SHELL followed by a command")
+	}
+	switch test[0] {
+	case "CMD":
+		return test[1:], nil
+	case "CMD-SHELL":
+		return []string{"/bin/sh", "-c", strings.Join(test[1:], " ")}, nil
+	default:
+		return nil, fmt.Errorf("unsupported test type %s", test[0])
+	}
+}
+
+// ImportCompose converts a docker-compose file into a millwright config file.
+// Build contexts are written relative to outDir, where the resulting file is meant to be saved.
+// It returns the YAML content of the config file, a list of warnings for compose keys that couldn't be imported,
+// or an error.
+func ImportCompose(path string, outDir string) ([]byte, []string, error) {
+	file, warnings, err := loadComposeFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	outDir, err = filepath.Abs(outDir)
+	if err != nil {
+		return nil, nil, err
+	}
+	for name, service := range file.Services {
+		if service.Context == "" {
+			continue
+		}
+		if relative, err := filepath.Rel(outDir, service.Context); err == nil {
+			service.Context = relative
+		}
+		file.Services[name] = service
+	}
+
+	var content bytes.Buffer
+	encoder := yaml.NewEncoder(&content)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(file); err != nil {
+		return nil, nil, err
+	}
+	return content.Bytes(), warnings, nil
+}
+
+// LoadComposeConfiguration returns the project described by a docker-compose file.
+// Compose keys that can't be imported are logged as warnings.
+func LoadComposeConfiguration(path string) (*Project, error) {
+	file, warnings, err := loadComposeFile(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, warning := range warnings {
+		log.Warnf("%s: %s", path, warning)
+	}
+	return file.project(filepath.Dir(path))
+}
+
+// sortedKeys returns the keys of a map in a deterministic order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,144 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadComposeFile(t *testing.T) {
+	t.Setenv("FROM_HOST", "hostvalue")
+
+	path := filepath.Join(t.TempDir(), "docker-compose.yml")
+	err := os.WriteFile(path, []byte(`
+version: "3.8"
+services:
+  db:
+    build: ./db
+    ports: ["5432:5432"]
+  api:
+    build:
+      context: ./api
+      dockerfile: Dockerfile.dev
+      args:
+        FOO: bar
+    environment:
+      DB_HOST: db
+      FROM_HOST:
+    depends_on:
+      db:
+        condition: service_healthy
+        restart: true
+    healthcheck:
+      test: ["CMD-SHELL", "curl -f http://localhost/"]
+      interval: 30s
+      retries: 3
+    stop_signal: SIGINT
+    stop_grace_period: 1m30s
+volumes:
+  data: {}
+`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, warnings, err := loadComposeFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := filepath.Dir(path)
+	api := file.Services["api"]
+	if api.Context != filepath.Join(dir, "api") || api.Dockerfile != "Dockerfile.dev" {
+		t.Errorf("Unexpected build config for api: %+v.", api)
+	}
+	// Values from the current environment are left out of the converted file, and taken when it's loaded.
+	if strings.Join(api.Env, ",") != "DB_HOST=db" || strings.Join(api.HostEnv, ",") != "FROM_HOST" {
+		t.Errorf("Unexpected env for api: %v, from the host: %v.", api.Env, api.HostEnv)
+	}
+	project, err := LoadComposeConfiguration(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, component := range project.Components {
+		if env := component.runConfig.Env; component.serviceName == "api" &&
+			strings.Join(env, ",") != "DB_HOST=db,FROM_HOST=hostvalue" {
+			t.Errorf("Unexpected env for the loaded api: %v.", env)
+		}
+	}
+	if len(api.Dependencies) != 1 || api.Dependencies[0] != "db" {
+		t.Errorf("Unexpected dependencies for api: %v.", api.Dependencies)
+	}
+	if api.OnRestart["db"] != string(DependencyRestart) {
+		t.Errorf("Unexpected dependency policies for api: %v.", api.OnRestart)
+	}
+	if probe := api.Probe; probe == nil || probe.Type != string(ExecProbe) ||
+		strings.Join(probe.Command, " ") != "/bin/sh -c curl -f http://localhost/" ||
+		probe.Period != 30*time.Second || probe.FailureThreshold != 3 {
+		t.Errorf("Unexpected probe for api: %+v.", api.Probe)
+	}
+	if api.StopSignal != "SIGINT" || api.StopGrace != 90*time.Second {
+		t.Errorf("Unexpected stop settings for api: %s after %s.", api.StopSignal, api.StopGrace)
+	}
+	if db := file.Services["db"]; db.Context != filepath.Join(dir, "db") {
+		t.Errorf("Unexpected build context for db: %s.", db.Context)
+	}
+
+	expectedWarnings := []string{
+		"top-level key volumes is not supported",
+		"service api: key build.args is not supported",
+		"service db: key ports is not supported",
+	}
+	if strings.Join(warnings, "\n") != strings.Join(expectedWarnings, "\n") {
+		t.Errorf("Unexpected warnings:\n%s", strings.Join(warnings, "\n"))
+	}
+}
+
+func TestLoadComposeFileImages(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "docker-compose.yml")
+	err := os.WriteFile(path, []byte(`
+services:
+  db:
+    image: postgres:14
+    pull_policy: missing
+  api:
+    build: .
+    image: registry.example.com/api
+`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, warnings, err := loadComposeFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if db := file.Services["db"]; db.Image != "postgres:14" || db.PullPolicy != string(PullIfNotPresent) || db.Context != "" {
+		t.Errorf("Unexpected config for db: %+v.", db)
+	}
+	if api := file.Services["api"]; api.Image != "" || api.Context == "" {
+		t.Errorf("Built service api should not use an image: %+v.", api)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "image registry.example.com/api is ignored") {
+		t.Errorf("Unexpected warnings: %v.", warnings)
+	}
+}
+
+func TestLoadComposeFileWithoutBuildOrImage(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "docker-compose.yml")
+	err := os.WriteFile(path, []byte(`
+services:
+  db:
+    environment: [FOO=bar]
+`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := loadComposeFile(path); err == nil {
+		t.Fatal("Loading a service without a build section or an image should have failed.")
+	}
+}
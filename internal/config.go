@@ -57,6 +57,7 @@ func configureComponents() []*Component {
 			},
 		},
 		dependencies: []*Component{ingestion},
+		onRestart:    map[*Component]DependencyPolicy{ingestion: DependencyRestart},
 	}
 
 	cpuUsageHandler := &Component{
@@ -71,6 +72,7 @@ func configureComponents() []*Component {
 			},
 		},
 		dependencies: []*Component{dispatcher},
+		onRestart:    map[*Component]DependencyPolicy{dispatcher: DependencyRestart},
 	}
 
 	kernelUpgradeHandler := &Component{
@@ -85,6 +87,7 @@ func configureComponents() []*Component {
 			},
 		},
 		dependencies: []*Component{dispatcher},
+		onRestart:    map[*Component]DependencyPolicy{dispatcher: DependencyRestart},
 	}
 
 	loadHandler := &Component{
@@ -99,6 +102,7 @@ func configureComponents() []*Component {
 			},
 		},
 		dependencies: []*Component{dispatcher},
+		onRestart:    map[*Component]DependencyPolicy{dispatcher: DependencyRestart},
 	}
 
 	return []*Component{
@@ -0,0 +1,240 @@
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// configFile is the on-disk representation of a millwright project.
+type configFile struct {
+	timingConfig `yaml:",inline"`         // timing of all services
+	Services     map[string]serviceConfig `yaml:"services"`
+}
+
+// serviceConfig is the on-disk representation of a single Component.
+type serviceConfig struct {
+	Context        string            `yaml:"context,omitempty"`     // relative to the config file
+	Dockerfile     string            `yaml:"dockerfile,omitempty"`  // relative to the build context
+	Image          string            `yaml:"image,omitempty"`       // used instead of building from a Dockerfile
+	PullPolicy     string            `yaml:"pull_policy,omitempty"` // always, if-not-present or never
+	Env            []string          `yaml:"env,omitempty"`         // in KEY=VALUE format
+	HostEnv        []string          `yaml:"host_env,omitempty"`    // variables taken from the environment when loaded
+	Dependencies   []string          `yaml:"dependencies,omitempty"`
+	OnRestart      map[string]string `yaml:"on_dependency_restart,omitempty"` // dependency -> none, restart or signal
+	Ignore         bool              `yaml:"ignore,omitempty"`
+	Probe          *probeConfig      `yaml:"probe,omitempty"`
+	ReadinessProbe *probeConfig      `yaml:"readiness_probe,omitempty"`   // defaults to probe
+	StartupTimeout time.Duration     `yaml:"startup_timeout,omitempty"`   // time to become ready, defaults to 1m
+	StopSignal     string            `yaml:"stop_signal,omitempty"`       // defaults to SIGTERM
+	StopGrace      time.Duration     `yaml:"stop_grace_period,omitempty"` // time to exit before being killed, defaults to 10s
+	timingConfig   `yaml:",inline"`  // defaults to the timing of the project
+}
+
+// timingConfig is the on-disk representation of a Timing.
+type timingConfig struct {
+	Interval       time.Duration `yaml:"interval,omitempty"`
+	FailureTimeout time.Duration `yaml:"failure_timeout,omitempty"`
+	GracePeriod    time.Duration `yaml:"grace_period,omitempty"`
+}
+
+// timing converts the config into a Timing.
+func (t timingConfig) timing() Timing {
+	return Timing{
+		Interval:       t.Interval,
+		FailureTimeout: t.FailureTimeout,
+		GracePeriod:    t.GracePeriod,
+	}
+}
+
+// probeConfig is the on-disk representation of a Probe.
+type probeConfig struct {
+	Type             string        `yaml:"type,omitempty"` // http, tcp, exec or docker
+	Port             int           `yaml:"port,omitempty"`
+	Path             string        `yaml:"path,omitempty"`
+	Status           []int         `yaml:"status,omitempty"`
+	JSON             *jsonConfig   `yaml:"json,omitempty"`
+	Command          []string      `yaml:"command,omitempty"`
+	ExitCode         int           `yaml:"exit_code,omitempty"`
+	Timeout          time.Duration `yaml:"timeout,omitempty"`
+	Period           time.Duration `yaml:"period,omitempty"`
+	SuccessThreshold int           `yaml:"success_threshold,omitempty"`
+	FailureThreshold int           `yaml:"failure_threshold,omitempty"`
+}
+
+// jsonConfig is the on-disk representation of the JSON body assertion of an HTTP probe.
+type jsonConfig struct {
+	Field  string `yaml:"field"`
+	Equals string `yaml:"equals"`
+}
+
+// probe converts the config into a Probe.
+func (p *probeConfig) probe() *Probe {
+	if p == nil {
+		return nil
+	}
+	probe := &Probe{
+		Type:             ProbeType(p.Type),
+		Port:             p.Port,
+		Path:             p.Path,
+		ExpectedStatus:   p.Status,
+		Command:          p.Command,
+		ExitCode:         p.ExitCode,
+		Timeout:          p.Timeout,
+		Period:           p.Period,
+		SuccessThreshold: p.SuccessThreshold,
+		FailureThreshold: p.FailureThreshold,
+	}
+	if p.JSON != nil {
+		probe.JSONField = p.JSON.Field
+		probe.JSONEquals = p.JSON.Equals
+	}
+	return probe
+}
+
+// Project is a set of components together with the settings that apply to all of them.
+type Project struct {
+	Name       string // prefixes the names of the resources of the project, see ProjectName
+	Components []*Component
+	Timing     Timing // timing of the components that don't specify their own
+}
+
+// LoadConfiguration returns the project described by the config file at the given path.
+// If no path is given, the components configured in config.go are used instead.
+// The project is named after the directory of the config file, or the current directory without one.
+func LoadConfiguration(path string) (*Project, error) {
+	if path == "" {
+		return &Project{Name: ConfigProjectName(""), Components: configureComponents()}, nil
+	}
+	return loadConfigFile(path)
+}
+
+// loadConfigFile reads and parses a YAML config file.
+// Relative build context paths are resolved against the directory of the file.
+func loadConfigFile(path string) (*Project, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("can't read config file: %v", err)
+	}
+
+	var file configFile
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&file); err != nil {
+		return nil, fmt.Errorf("can't parse config file %s: %v", path, err)
+	}
+
+	dir, err := filepath.Abs(filepath.Dir(path))
+	if err != nil {
+		return nil, err
+	}
+
+	return file.project(dir)
+}
+
+// project converts a config file into a project.
+func (f *configFile) project(dir string) (*Project, error) {
+	components, err := f.components(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &Project{Name: ProjectName(dir), Components: components, Timing: f.timing()}, nil
+}
+
+// components converts the services of a config file into components.
+// Components are returned sorted by service name so that the startup order is deterministic.
+func (f *configFile) components(dir string) ([]*Component, error) {
+	names := sortedKeys(f.Services)
+
+	byName := make(map[string]*Component, len(names))
+	components := make([]*Component, 0, len(names))
+	for _, name := range names {
+		service := f.Services[name]
+
+		// Services that run an image are not built, so the build defaults don't apply to them.
+		buildContext := service.Context
+		dockerfile := service.Dockerfile
+		if service.Image == "" {
+			if buildContext == "" {
+				buildContext = "."
+			}
+			if dockerfile == "" {
+				dockerfile = "Dockerfile"
+			}
+		}
+		if buildContext != "" && !filepath.IsAbs(buildContext) {
+			buildContext = filepath.Join(dir, buildContext)
+		}
+
+		env := append(append([]string{}, service.Env...), hostEnv(name, service.HostEnv)...)
+
+		component := &Component{
+			serviceName: name,
+			runConfig: RunConfiguration{
+				DockerfilePath:   dockerfile,
+				BuildContextPath: buildContext,
+				Image:            service.Image,
+				PullPolicy:       PullPolicy(service.PullPolicy),
+				Env:              env,
+				StopSignal:       service.StopSignal,
+				StopGracePeriod:  service.StopGrace,
+			},
+			dependencies:   []*Component{},
+			ignore:         service.Ignore,
+			probe:          service.Probe.probe(),
+			readinessProbe: service.ReadinessProbe.probe(),
+			startupTimeout: service.StartupTimeout,
+			timing:         service.timing(),
+		}
+		byName[name] = component
+		components = append(components, component)
+	}
+
+	// Resolve dependencies now that all components exist.
+	for _, component := range components {
+		for _, dependencyName := range f.Services[component.serviceName].Dependencies {
+			dependency, ok := byName[dependencyName]
+			if !ok {
+				return nil, fmt.Errorf(
+					"unknown dependency %s for service %s", dependencyName, component.serviceName,
+				)
+			}
+			component.dependencies = append(component.dependencies, dependency)
+		}
+
+		onRestart := f.Services[component.serviceName].OnRestart
+		if len(onRestart) > 0 {
+			component.onRestart = make(map[*Component]DependencyPolicy, len(onRestart))
+		}
+		for _, dependencyName := range sortedKeys(onRestart) {
+			dependency, ok := byName[dependencyName]
+			if !ok {
+				return nil, fmt.Errorf(
+					"restart policy for unknown dependency %s of service %s", dependencyName, component.serviceName,
+				)
+			}
+			component.onRestart[dependency] = DependencyPolicy(onRestart[dependencyName])
+		}
+	}
+
+	return components, nil
+}
+
+// hostEnv takes the variables of a service with the given names from the current environment, in KEY=VALUE format.
+// Variables that aren't set are dropped.
+func hostEnv(name string, keys []string) []string {
+	var env []string
+	for _, key := range keys {
+		value, ok := os.LookupEnv(key)
+		if !ok {
+			log.Warnf("service %s: environment variable %s is not set, dropping it", name, key)
+			continue
+		}
+		env = append(env, fmt.Sprintf("%s=%s", key, value))
+	}
+	return env
+}
@@ -0,0 +1,233 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "millwright.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	path := writeConfigFile(t, `
+services:
+  b:
+    context: ./b
+    env: [FOO=bar]
+    dependencies: [a]
+    probe:
+      type: http
+      path: /health
+      status: [200, 204]
+      json: {field: status, equals: ok}
+      timeout: 2s
+      failure_threshold: 3
+    readiness_probe:
+      type: tcp
+      port: 5432
+    startup_timeout: 2m
+  a:
+    context: /abs/a
+    dockerfile: build/Dockerfile
+    ignore: true
+`)
+
+	project, err := loadConfigFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	components := project.Components
+	if len(components) != 2 {
+		t.Fatalf("Expected 2 components, got %d.", len(components))
+	}
+
+	a, b := components[0], components[1]
+	if a.serviceName != "a" || b.serviceName != "b" {
+		t.Fatalf("Components not sorted by name: %s, %s.", a.serviceName, b.serviceName)
+	}
+	if !a.ignore || b.ignore {
+		t.Error("Ignore flag not loaded correctly.")
+	}
+	if a.runConfig.BuildContextPath != "/abs/a" || a.runConfig.DockerfilePath != "build/Dockerfile" {
+		t.Errorf("Unexpected run config for a: %+v.", a.runConfig)
+	}
+	if want := filepath.Join(filepath.Dir(path), "b"); b.runConfig.BuildContextPath != want {
+		t.Errorf("Build context not resolved against config dir: got %s, want %s.", b.runConfig.BuildContextPath, want)
+	}
+	if b.runConfig.DockerfilePath != "Dockerfile" {
+		t.Errorf("Default Dockerfile not applied: %s.", b.runConfig.DockerfilePath)
+	}
+	if len(b.runConfig.Env) != 1 || b.runConfig.Env[0] != "FOO=bar" {
+		t.Errorf("Unexpected env for b: %v.", b.runConfig.Env)
+	}
+	if len(b.dependencies) != 1 || b.dependencies[0] != a {
+		t.Error("Dependency of b not resolved to a.")
+	}
+	if a.probe != nil {
+		t.Error("Probe set for a without a probe config.")
+	}
+	if probe := b.probe; probe == nil || probe.Type != HTTPProbe || probe.Path != "/health" ||
+		len(probe.ExpectedStatus) != 2 || probe.JSONField != "status" || probe.JSONEquals != "ok" ||
+		probe.Timeout != 2*time.Second || probe.FailureThreshold != 3 {
+		t.Errorf("Probe not loaded correctly: %+v.", b.probe)
+	}
+	if probe := b.readinessProbe; probe == nil || probe.Type != TCPProbe || probe.Port != 5432 {
+		t.Errorf("Readiness probe not loaded correctly: %+v.", b.readinessProbe)
+	}
+	if b.startupTimeout != 2*time.Minute || a.startupTimeout != 0 {
+		t.Errorf("Unexpected startup timeouts %s and %s.", a.startupTimeout, b.startupTimeout)
+	}
+}
+
+func TestLoadConfigFileImage(t *testing.T) {
+	path := writeConfigFile(t, `
+services:
+  db:
+    image: postgres:14
+    pull_policy: always
+`)
+
+	project, err := loadConfigFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	components := project.Components
+
+	runConfig := components[0].runConfig
+	if runConfig.Image != "postgres:14" || runConfig.PullPolicy != PullAlways {
+		t.Errorf("Image not loaded correctly: %+v.", runConfig)
+	}
+	if runConfig.BuildContextPath != "" || runConfig.DockerfilePath != "" {
+		t.Errorf("Build defaults applied to an image: %+v.", runConfig)
+	}
+	if err := checkConfiguration(components, defaultTiming); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestLoadConfigFileHostEnv(t *testing.T) {
+	t.Setenv("FROM_HOST", "hostvalue")
+	path := writeConfigFile(t, `
+services:
+  db:
+    image: postgres:14
+    env: [FOO=bar]
+    host_env: [FROM_HOST, UNSET_ON_HOST]
+`)
+
+	project, err := loadConfigFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if env := project.Components[0].runConfig.Env; strings.Join(env, ",") != "FOO=bar,FROM_HOST=hostvalue" {
+		t.Errorf("Unexpected env: %v.", env)
+	}
+
+	// Entries of env without a value aren't taken from the host.
+	path = writeConfigFile(t, `
+services:
+  db:
+    image: postgres:14
+    env: [FROM_HOST]
+`)
+	project, err = loadConfigFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := checkConfiguration(project.Components, defaultTiming); err == nil ||
+		!strings.Contains(err.Error(), "malformed env entry") {
+		t.Errorf("Expected the entry without a value to be rejected, got: %v.", err)
+	}
+}
+
+func TestLoadConfigFileTiming(t *testing.T) {
+	path := writeConfigFile(t, `
+interval: 1s
+failure_timeout: 10s
+services:
+  a:
+    context: ./a
+  b:
+    context: ./b
+    failure_timeout: 30s
+    grace_period: 1m
+`)
+
+	project, err := loadConfigFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if project.Timing != (Timing{Interval: time.Second, FailureTimeout: 10 * time.Second}) {
+		t.Errorf("Project timing not loaded correctly: %+v.", project.Timing)
+	}
+	a, b := project.Components[0], project.Components[1]
+	if a.timing != (Timing{}) {
+		t.Errorf("Timing set for a without a timing config: %+v.", a.timing)
+	}
+	if b.timing != (Timing{FailureTimeout: 30 * time.Second, GracePeriod: time.Minute}) {
+		t.Errorf("Timing of b not loaded correctly: %+v.", b.timing)
+	}
+}
+
+func TestLoadConfigFileDependencyPolicies(t *testing.T) {
+	path := writeConfigFile(t, `
+services:
+  a:
+    context: ./a
+  b:
+    context: ./b
+  c:
+    context: ./c
+    dependencies: [a, b]
+    on_dependency_restart:
+      a: restart
+      b: signal
+`)
+
+	project, err := loadConfigFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, b, c := project.Components[0], project.Components[1], project.Components[2]
+	if c.dependencyPolicy(a) != DependencyRestart || c.dependencyPolicy(b) != DependencySignal {
+		t.Errorf("Dependency policies not loaded correctly: %v.", c.onRestart)
+	}
+	if b.dependencyPolicy(a) != DependencyNone {
+		t.Errorf("Unexpected dependency policy %s without a policy config.", b.dependencyPolicy(a))
+	}
+}
+
+func TestLoadConfigFileErrors(t *testing.T) {
+	cases := map[string]string{
+		"unknown dependency": `
+services:
+  a:
+    dependencies: [missing]
+`,
+		"policy for unknown dependency": `
+services:
+  a:
+    on_dependency_restart: {missing: restart}
+`,
+		"unknown field": `
+services:
+  a:
+    dockerfil: Dockerfile
+`,
+	}
+
+	for name, content := range cases {
+		if _, err := loadConfigFile(writeConfigFile(t, content)); err == nil {
+			t.Errorf("%s: loading should have failed.", name)
+		}
+	}
+}
@@ -0,0 +1,408 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ControlSocket returns the path of the Unix socket that the running millwright of the project with the given name
+// serves its control API on.
+func ControlSocket(project string) string {
+	return filepath.Join(os.TempDir(), "millwright", project+".sock")
+}
+
+// controlRequest is the body of the requests of the control API that act on components.
+type controlRequest struct {
+	Components []string `json:"components,omitempty"` // all components if empty
+}
+
+// controlError is the body of the responses of the control API to requests that failed.
+type controlError struct {
+	Error string `json:"error"`
+}
+
+// ErrReloadUnsupported is returned by the control API when the running millwright can't reload its configuration.
+var ErrReloadUnsupported = errors.New("the running millwright can't reload its configuration")
+
+// controlServer serves the control API of a running millwright.
+type controlServer struct {
+	mw *Millwright
+	// ctx carries the values of the project, and outlives the requests so that the actions they start are completed
+	// even if the client goes away.
+	ctx context.Context
+	// reload reloads the configuration of the millwright, if it can.
+	reload func(ctx context.Context) (*ReloadResult, error)
+}
+
+// handler returns the routes of the control API.
+func (s *controlServer) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", s.get(func() (interface{}, error) {
+		status, err := s.mw.Status(s.ctx)
+		if err != nil {
+			return nil, err
+		}
+		status.Monitored = true
+		status.Paused = s.mw.isPaused()
+		return status, nil
+	}))
+	mux.HandleFunc("/events", s.events)
+	mux.HandleFunc("/restart", s.post(func(request controlRequest) (interface{}, error) {
+		return nil, s.mw.Restart(s.ctx, request.Components)
+	}))
+	mux.HandleFunc("/stop", s.post(func(request controlRequest) (interface{}, error) {
+		return nil, s.mw.Stop(s.ctx, request.Components)
+	}))
+	mux.HandleFunc("/kill", s.post(func(request controlRequest) (interface{}, error) {
+		return nil, s.mw.Kill(s.ctx, request.Components)
+	}))
+	mux.HandleFunc("/pause", s.post(func(controlRequest) (interface{}, error) {
+		s.mw.setPaused(true)
+		return nil, nil
+	}))
+	mux.HandleFunc("/resume", s.post(func(controlRequest) (interface{}, error) {
+		s.mw.setPaused(false)
+		return nil, nil
+	}))
+	mux.HandleFunc("/reload", s.post(func(controlRequest) (interface{}, error) {
+		if s.reload == nil {
+			return nil, ErrReloadUnsupported
+		}
+		return s.reload(s.ctx)
+	}))
+	return mux
+}
+
+// get handles the GET requests of a route with a function that returns the body of the response.
+func (s *controlServer) get(handle func() (interface{}, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeControlResponse(w, http.StatusMethodNotAllowed, controlError{Error: "expected GET"})
+			return
+		}
+		body, err := handle()
+		if err != nil {
+			writeControlResponse(w, http.StatusInternalServerError, controlError{Error: err.Error()})
+			return
+		}
+		writeControlResponse(w, http.StatusOK, body)
+	}
+}
+
+// events streams the transitions of the components as JSON, one per line, until the client or the server goes away.
+func (s *controlServer) events(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeControlResponse(w, http.StatusMethodNotAllowed, controlError{Error: "expected GET"})
+		return
+	}
+	transitions, unsubscribe := s.mw.Subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	// Let the client know it's subscribed before the first transition.
+	if flusher != nil {
+		flusher.Flush()
+	}
+	encoder := json.NewEncoder(w)
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case transition := <-transitions:
+			if err := encoder.Encode(transition); err != nil {
+				return
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+	}
+}
+
+// post handles the POST requests of a route with a function that acts on the components of the request,
+// and returns the body of the response if there is one.
+func (s *controlServer) post(handle func(request controlRequest) (interface{}, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			writeControlResponse(w, http.StatusMethodNotAllowed, controlError{Error: "expected POST"})
+			return
+		}
+		var request controlRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil && err != io.EOF {
+			writeControlResponse(w, http.StatusBadRequest, controlError{Error: err.Error()})
+			return
+		}
+		body, err := handle(request)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if err == ErrReloadUnsupported {
+				status = http.StatusNotImplemented
+			}
+			writeControlResponse(w, status, controlError{Error: err.Error()})
+			return
+		}
+		if body == nil {
+			body = struct{}{}
+		}
+		writeControlResponse(w, http.StatusOK, body)
+	}
+}
+
+// writeControlResponse writes a JSON response.
+func writeControlResponse(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Errorf("Can't write the response of the control API: %v", err)
+	}
+}
+
+// ServeControl serves the control API on a Unix socket at the given path until the context is done.
+// The context has to carry the values of the project. A socket left behind by a millwright that is gone is replaced.
+// Reloading the configuration is only supported if a reload function is given.
+func (mw *Millwright) ServeControl(
+	ctx context.Context, socket string, reload func(ctx context.Context) (*ReloadResult, error),
+) error {
+	if err := os.MkdirAll(filepath.Dir(socket), 0o755); err != nil {
+		return err
+	}
+	if conn, err := net.DialTimeout("unix", socket, time.Second); err == nil {
+		conn.Close()
+		return fmt.Errorf("another millwright is serving its control API on %s", socket)
+	}
+	_ = os.Remove(socket)
+
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		return err
+	}
+	// The socket is only removed if it's still ours, since a millwright that was started with --force may have
+	// replaced it already.
+	listener.(*net.UnixListener).SetUnlinkOnClose(false)
+	own, err := os.Stat(socket)
+	if err != nil {
+		listener.Close()
+		return err
+	}
+	defer func() {
+		if current, err := os.Stat(socket); err == nil && os.SameFile(own, current) {
+			_ = os.Remove(socket)
+		}
+	}()
+
+	server := &http.Server{Handler: (&controlServer{mw: mw, ctx: ctx, reload: reload}).handler()}
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+
+	log.Infof("Serving the control API on %s.", socket)
+	if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// setPaused pauses or resumes monitoring the components.
+func (mw *Millwright) setPaused(paused bool) {
+	mw.mu.Lock()
+	defer mw.mu.Unlock()
+
+	if paused != mw.paused {
+		if paused {
+			log.Info("Monitoring paused, failed components won't be restarted until it's resumed.")
+			mw.resumed = make(chan struct{})
+		} else {
+			log.Info("Monitoring resumed.")
+			close(mw.resumed)
+		}
+	}
+	mw.paused = paused
+}
+
+// waitResumed blocks while monitoring is paused. It returns false if the context is done first.
+func (mw *Millwright) waitResumed(ctx context.Context) bool {
+	mw.mu.Lock()
+	paused, resumed := mw.paused, mw.resumed
+	mw.mu.Unlock()
+
+	if !paused {
+		return true
+	}
+	select {
+	case <-ctx.Done():
+		return false
+	case <-resumed:
+		return true
+	}
+}
+
+// isPaused tells whether monitoring the components is paused.
+func (mw *Millwright) isPaused() bool {
+	mw.mu.Lock()
+	defer mw.mu.Unlock()
+
+	return mw.paused
+}
+
+// ControlClient sends requests to the control API of the running millwright of a project.
+type ControlClient struct {
+	client *http.Client
+}
+
+// DialControl returns a client of the control API of the running millwright of the project with the given name,
+// and whether one is running.
+func DialControl(project string) (*ControlClient, bool) {
+	return dialControl(ControlSocket(project))
+}
+
+// dialControl returns a client of the control API served on the Unix socket at the given path,
+// and whether it's being served.
+func dialControl(socket string) (*ControlClient, bool) {
+	conn, err := net.DialTimeout("unix", socket, time.Second)
+	if err != nil {
+		return nil, false
+	}
+	conn.Close()
+
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "unix", socket)
+		},
+	}
+	return &ControlClient{client: &http.Client{Transport: transport}}, true
+}
+
+// Status returns the status of the project as reported by the running millwright.
+func (c *ControlClient) Status(ctx context.Context) (*ProjectStatus, error) {
+	var status ProjectStatus
+	if err := c.do(ctx, http.MethodGet, "/status", nil, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// Restart asks the running millwright to restart the components with the given names, or all of them if no names
+// are given, and waits until they're ready.
+func (c *ControlClient) Restart(ctx context.Context, names []string) error {
+	return c.do(ctx, http.MethodPost, "/restart", controlRequest{Components: names}, nil)
+}
+
+// Stop asks the running millwright to stop the components with the given names, or all of them if no names are
+// given, and to keep them stopped.
+func (c *ControlClient) Stop(ctx context.Context, names []string) error {
+	return c.do(ctx, http.MethodPost, "/stop", controlRequest{Components: names}, nil)
+}
+
+// Kill asks the running millwright to remove the containers of the components with the given names by force, or
+// of all of them if no names are given, and to keep the components stopped.
+func (c *ControlClient) Kill(ctx context.Context, names []string) error {
+	return c.do(ctx, http.MethodPost, "/kill", controlRequest{Components: names}, nil)
+}
+
+// Pause asks the running millwright to stop monitoring the components until Resume is called.
+func (c *ControlClient) Pause(ctx context.Context) error {
+	return c.do(ctx, http.MethodPost, "/pause", nil, nil)
+}
+
+// Resume asks the running millwright to monitor the components again.
+func (c *ControlClient) Resume(ctx context.Context) error {
+	return c.do(ctx, http.MethodPost, "/resume", nil, nil)
+}
+
+// Reload asks the running millwright to reload its configuration and to apply the changes, and waits until the
+// components that were added or changed have been launched.
+func (c *ControlClient) Reload(ctx context.Context) (*ReloadResult, error) {
+	var result ReloadResult
+	if err := c.do(ctx, http.MethodPost, "/reload", nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Events streams the transitions of the components of the running millwright to the given function, until the
+// context is done or the millwright goes away.
+func (c *ControlClient) Events(ctx context.Context, handle func(transition Transition)) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://millwright/events", nil)
+	if err != nil {
+		return err
+	}
+	response, err := c.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if err := checkControlResponse(response); err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(response.Body)
+	for {
+		var transition Transition
+		if err := decoder.Decode(&transition); err != nil {
+			if err == io.EOF || ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		handle(transition)
+	}
+}
+
+// do sends a request to the control API and decodes its response into out, if given.
+func (c *ControlClient) do(ctx context.Context, method string, path string, in interface{}, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	// The host is ignored, requests are sent to the socket.
+	request, err := http.NewRequestWithContext(ctx, method, "http://millwright"+path, body)
+	if err != nil {
+		return err
+	}
+	response, err := c.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if err := checkControlResponse(response); err != nil {
+		return err
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(response.Body).Decode(out)
+}
+
+// checkControlResponse returns the error the control API responded with, if any.
+func checkControlResponse(response *http.Response) error {
+	if response.StatusCode == http.StatusNotImplemented {
+		return ErrReloadUnsupported
+	}
+	if response.StatusCode != http.StatusOK {
+		var failure controlError
+		if err := json.NewDecoder(response.Body).Decode(&failure); err != nil || failure.Error == "" {
+			return fmt.Errorf("the running millwright responded with %s", response.Status)
+		}
+		return errors.New(failure.Error)
+	}
+	return nil
+}
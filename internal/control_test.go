@@ -0,0 +1,166 @@
+package internal
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestControlAPI(t *testing.T) {
+	defer func(delay time.Duration) { restartBackoffBase = delay }(restartBackoffBase)
+	restartBackoffBase = 10 * time.Millisecond
+
+	backend := newFakeBackend()
+	defer backend.close()
+	mw := testMillwright(t, backend)
+	mw.timing = fastTiming
+	ctx := testContext(t)
+	if err := mw.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer reconcile(ctx, mw)()
+
+	socket := filepath.Join(t.TempDir(), "control.sock")
+	served := make(chan error, 1)
+	go func() { served <- mw.ServeControl(ctx, socket, nil) }()
+	var client *ControlClient
+	waitFor(t, "the control API to be served", func() bool {
+		var ok bool
+		client, ok = dialControl(socket)
+		return ok
+	})
+
+	status, err := client.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !status.Monitored || status.Paused || len(status.Components) != len(mw.components) {
+		t.Errorf("Unexpected status: %+v.", status)
+	}
+
+	// Components that are stopped through the running millwright stay stopped.
+	if err := client.Stop(ctx, []string{"handler_load"}); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if backend.container("handler_load").Running || mw.components[1].currentStatus() != Stopped {
+		t.Error("Stopped component was restarted.")
+	}
+	if err := client.Restart(ctx, []string{"handler_load"}); err != nil {
+		t.Fatal(err)
+	}
+	if !backend.container("handler_load").Running {
+		t.Error("Restarted component isn't running.")
+	}
+
+	// Components that are killed through the running millwright aren't taken for crashed.
+	if err := client.Kill(ctx, []string{"handler_load"}); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if backend.container("handler_load") != nil || mw.components[1].currentStatus() != Stopped {
+		t.Error("Killed component was restarted.")
+	}
+	if err := client.Restart(ctx, []string{"handler_load"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Failures aren't handled while monitoring is paused.
+	if err := client.Pause(ctx); err != nil {
+		t.Fatal(err)
+	}
+	crashed := backend.container("handler_cpu").ID
+	backend.crash("handler_cpu")
+	time.Sleep(100 * time.Millisecond)
+	if backend.container("handler_cpu").ID != crashed {
+		t.Fatal("Component was restarted while monitoring was paused.")
+	}
+	if status, err := client.Status(ctx); err != nil || !status.Paused {
+		t.Errorf("Expected monitoring to be reported as paused, got %+v: %v.", status, err)
+	}
+	if err := client.Resume(ctx); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "handler_cpu to be restarted", func() bool {
+		c := backend.container("handler_cpu")
+		return c != nil && c.ID != crashed && mw.components[0].currentStatus() == Ready
+	})
+
+	if err := client.Restart(ctx, []string{"handler"}); err == nil || !strings.Contains(err.Error(), "unknown component") {
+		t.Errorf("Expected an unknown component to be reported, got: %v.", err)
+	}
+	if _, err := client.Reload(ctx); err != ErrReloadUnsupported {
+		t.Errorf("Expected reloading to be unsupported, got: %v.", err)
+	}
+}
+
+func TestServeControlRefusesLiveSocket(t *testing.T) {
+	backend := newFakeBackend()
+	defer backend.close()
+	ctx := testContext(t)
+	socket := filepath.Join(t.TempDir(), "control.sock")
+
+	go func() { _ = testMillwright(t, backend).ServeControl(ctx, socket, nil) }()
+	waitFor(t, "the control API to be served", func() bool {
+		_, ok := dialControl(socket)
+		return ok
+	})
+
+	err := testMillwright(t, backend).ServeControl(ctx, socket, nil)
+	if err == nil || !strings.Contains(err.Error(), "another millwright") {
+		t.Errorf("Expected the socket of the running millwright to be kept, got: %v.", err)
+	}
+	if _, ok := dialControl(socket); !ok {
+		t.Error("The control API of the running millwright is gone.")
+	}
+}
+
+func TestControlAPIEvents(t *testing.T) {
+	backend := newFakeBackend()
+	defer backend.close()
+	mw := testMillwright(t, backend)
+	ctx := testContext(t)
+	if err := mw.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	socket := filepath.Join(t.TempDir(), "control.sock")
+	go func() { _ = mw.ServeControl(ctx, socket, nil) }()
+	var client *ControlClient
+	waitFor(t, "the control API to be served", func() bool {
+		var ok bool
+		client, ok = dialControl(socket)
+		return ok
+	})
+
+	streamed := make(chan Transition, subscriberBuffer)
+	streamCtx, cancel := context.WithCancel(ctx)
+	done := make(chan error, 1)
+	go func() {
+		done <- client.Events(streamCtx, func(transition Transition) { streamed <- transition })
+	}()
+	waitFor(t, "the client to subscribe", func() bool {
+		mw.mu.Lock()
+		defer mw.mu.Unlock()
+		return len(mw.subscribers) > 0
+	})
+
+	if err := mw.Stop(ctx, []string{"handler_load"}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case transition := <-streamed:
+		if transition.Component != "handler_load" || transition.To != Stopped {
+			t.Errorf("Unexpected transition %+v.", transition)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("The transition wasn't streamed.")
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("Expected the stream to end without an error, got: %v.", err)
+	}
+}
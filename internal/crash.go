@@ -0,0 +1,158 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// crashFileTime is the layout of the names of crash files, which sort in the order the crashes happened.
+const crashFileTime = "20060102T150405.000000000Z"
+
+// Crash is the record of a failed container of a component, saved before the component is relaunched.
+type Crash struct {
+	Component   string    `json:"component"`
+	ContainerID string    `json:"container_id"`
+	Time        time.Time `json:"time"`
+	Reason      string    `json:"reason,omitempty"` // why the container stopped, from its events
+	Running     bool      `json:"running"`          // still running but failing its health checks
+	ExitCode    int       `json:"exit_code"`
+	OOMKilled   bool      `json:"oom_killed"`
+	Logs        []string  `json:"logs"` // last lines of the combined stdout and stderr
+}
+
+// Summary describes a crash in one line.
+func (c *Crash) Summary() string {
+	var summary strings.Builder
+	fmt.Fprintf(&summary, "%s container %.12s ", c.Time.Local().Format(time.RFC3339), c.ContainerID)
+	switch {
+	case c.Reason != "":
+		summary.WriteString(c.Reason)
+	case c.Running:
+		summary.WriteString("was failing its health checks")
+	default:
+		fmt.Fprintf(&summary, "exited with code %d", c.ExitCode)
+	}
+	if c.OOMKilled {
+		summary.WriteString(" (out of memory)")
+	}
+	return summary.String()
+}
+
+// DefaultCrashDir returns the directory that crashes are saved to by default.
+func DefaultCrashDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "millwright", "crashes")
+}
+
+// saveCrash saves the exit code, OOM flag and last lines of logs of the current container of a failed component
+// to the directory of its project in the crash directory, and deletes its oldest crashes beyond crashesKept.
+// It has to be called before the container is removed.
+// It returns the path of the saved crash, which is empty if crashes aren't saved.
+func (mw *Millwright) saveCrash(ctx context.Context, component *Component) (string, error) {
+	containerID, _ := component.container()
+	if mw.crashDir == "" || containerID == "" {
+		return "", nil
+	}
+
+	inspect, err := mw.backend.InspectContainer(ctx, containerID)
+	if err != nil {
+		return "", err
+	}
+	crash := Crash{
+		Component:   component.serviceName,
+		ContainerID: containerID,
+		Time:        time.Now().UTC(),
+		Reason:      component.lastExitReason(),
+		Running:     inspect.Running,
+		ExitCode:    inspect.ExitCode,
+		OOMKilled:   inspect.OOMKilled,
+		Logs:        []string{},
+	}
+
+	lines, errs := mw.backend.StreamLogs(ctx, containerID, LogOptions{Tail: mw.crashLines})
+	for line := range lines {
+		crash.Logs = append(crash.Logs, line.Text)
+	}
+	select {
+	case err := <-errs:
+		return "", fmt.Errorf("can't read logs: %v", err)
+	default:
+	}
+
+	dir := filepath.Join(mw.crashDir, ctx.Value(projectNameKey).(string), component.serviceName)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	data, err := json.MarshalIndent(crash, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, crash.Time.Format(crashFileTime)+".json")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return "", err
+	}
+
+	return path, pruneCrashes(dir)
+}
+
+// crashFiles returns the paths of the crash files in a directory, oldest first.
+func crashFiles(dir string) ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// pruneCrashes deletes the oldest crashes in a directory beyond crashesKept.
+func pruneCrashes(dir string) error {
+	paths, err := crashFiles(dir)
+	if err != nil {
+		return err
+	}
+	for len(paths) > crashesKept {
+		if err := os.Remove(paths[0]); err != nil {
+			return err
+		}
+		paths = paths[1:]
+	}
+	return nil
+}
+
+// LoadCrashes returns the saved crashes of the component with the given name in the given project, oldest first.
+func LoadCrashes(crashDir string, project string, name string) ([]*Crash, error) {
+	if err := CheckProjectName(project); err != nil {
+		return nil, err
+	}
+	if name == "" || name != filepath.Base(name) {
+		return nil, fmt.Errorf("invalid component name %q", name)
+	}
+	paths, err := crashFiles(filepath.Join(crashDir, project, name))
+	if err != nil {
+		return nil, err
+	}
+
+	crashes := make([]*Crash, 0, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var crash Crash
+		if err := json.Unmarshal(data, &crash); err != nil {
+			return nil, fmt.Errorf("can't parse crash %s: %v", path, err)
+		}
+		crashes = append(crashes, &crash)
+	}
+	return crashes, nil
+}
@@ -0,0 +1,65 @@
+package internal
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHandleFailedComponentSavesCrashes(t *testing.T) {
+	defer func(delay time.Duration, kept int) {
+		restartBackoffBase, crashesKept = delay, kept
+	}(restartBackoffBase, crashesKept)
+	restartBackoffBase = 10 * time.Millisecond
+	crashesKept = 2
+
+	backend := newFakeBackend()
+	defer backend.close()
+	mw := testMillwright(t, backend)
+	mw.crashDir = t.TempDir()
+	mw.crashLines = 2
+	ctx := testContext(t)
+	if err := mw.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	component := mw.components[0]
+	var containers []string
+	for i := 0; i < 3; i++ {
+		containers = append(containers, backend.container(component.serviceName).ID)
+		backend.write(component.serviceName, "starting up")
+		backend.write(component.serviceName, "almost there")
+		if i == 2 {
+			backend.mu.Lock()
+			backend.find(component.serviceName).OOMKilled = true
+			backend.mu.Unlock()
+		}
+		backend.crash(component.serviceName)
+		if err := mw.transition(component, Failed); err != nil {
+			t.Fatal(err)
+		}
+		mw.HandleFailedComponent(ctx, component)
+	}
+
+	crashes, err := LoadCrashes(mw.crashDir, testProject, component.serviceName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(crashes) != 2 {
+		t.Fatalf("Expected the last 2 crashes to be kept, got %d.", len(crashes))
+	}
+	if crashes[0].ContainerID != containers[1] || crashes[1].ContainerID != containers[2] {
+		t.Errorf("Unexpected crashes were kept: %s and %s.", crashes[0].ContainerID, crashes[1].ContainerID)
+	}
+
+	last := crashes[1]
+	if last.ExitCode != 2 || !last.OOMKilled || last.Running {
+		t.Errorf("Unexpected state of the crashed container: %+v.", last)
+	}
+	if len(last.Logs) != 2 || last.Logs[0] != "almost there" || last.Logs[1] != "panic: handler_cpu crashed" {
+		t.Errorf("Unexpected logs: %q.", last.Logs)
+	}
+
+	if _, err := LoadCrashes(mw.crashDir, testProject, "../handler_cpu"); err == nil {
+		t.Error("Loading crashes outside of the crash directory should have failed.")
+	}
+}
@@ -0,0 +1,480 @@
+package internal
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/archive"
+	"github.com/docker/docker/pkg/jsonmessage"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/docker/go-connections/nat"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DockerBackend is the Backend that runs components on the local Docker engine.
+type DockerBackend struct {
+	cli *client.Client
+}
+
+var _ Backend = (*DockerBackend)(nil)
+
+// NewDockerBackend is a factory method for DockerBackend.
+// The Docker client is configured from the environment.
+func NewDockerBackend() (*DockerBackend, error) {
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return nil, err
+	}
+	return &DockerBackend{cli: cli}, nil
+}
+
+// ListNetworks returns the IDs of the networks with exactly the given name.
+func (b *DockerBackend) ListNetworks(ctx context.Context, name string) ([]string, error) {
+	list, err := b.cli.NetworkList(ctx, types.NetworkListOptions{
+		Filters: filters.NewArgs(filters.Arg("name", name)),
+	})
+	if err != nil {
+		return nil, err
+	}
+	// The name filter matches substrings, which would mix up the networks of projects whose names overlap.
+	ids := make([]string, 0, len(list))
+	for _, n := range list {
+		if n.Name == name {
+			ids = append(ids, n.ID)
+		}
+	}
+	return ids, nil
+}
+
+// CreateNetwork creates a bridge network with the given labels.
+func (b *DockerBackend) CreateNetwork(ctx context.Context, name string, labels map[string]string) (string, error) {
+	net, err := b.cli.NetworkCreate(ctx, name, types.NetworkCreate{
+		Driver: "bridge",
+		Labels: labels,
+	})
+	if err != nil {
+		return "", err
+	}
+	return net.ID, nil
+}
+
+// BuildImage tars the build context and builds it, writing the build output to stdout.
+func (b *DockerBackend) BuildImage(ctx context.Context, options BuildOptions) error {
+	// Create a tar of the build context folder, leaving out the files in .dockerignore like the Docker CLI does.
+	excludes, err := readDockerignore(options.ContextPath)
+	if err != nil {
+		return err
+	}
+	tar, err := archive.TarWithOptions(options.ContextPath, &archive.TarOptions{ExcludePatterns: excludes})
+	if err != nil {
+		return err
+	}
+
+	res, err := b.cli.ImageBuild(ctx, tar, types.ImageBuildOptions{
+		Dockerfile:  options.DockerfilePath,
+		PullParent:  true,
+		Remove:      true,
+		ForceRemove: true,
+		Tags:        []string{options.Tag},
+		Labels:      options.Labels,
+	})
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	return writeProgress(res.Body, options.Tag)
+}
+
+// PullImage pulls an image, writing the progress output to stdout.
+func (b *DockerBackend) PullImage(ctx context.Context, ref string) error {
+	res, err := b.cli.ImagePull(ctx, ref, types.ImagePullOptions{})
+	if err != nil {
+		return err
+	}
+	defer res.Close()
+	return writeProgress(res, ref)
+}
+
+// writeProgress writes the messages of a build or pull to stdout, each line prefixed with the given name so that
+// concurrent builds can be told apart. Progress bars are left out.
+// It returns the error that ended the build or pull, if any.
+func writeProgress(body io.Reader, name string) error {
+	decoder := json.NewDecoder(body)
+	for {
+		var message jsonmessage.JSONMessage
+		if err := decoder.Decode(&message); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if message.Error != nil {
+			return message.Error
+		}
+
+		text := message.Stream
+		if text == "" && message.Progress == nil && message.Status != "" {
+			text = message.Status
+			if message.ID != "" {
+				text = message.ID + ": " + text
+			}
+		}
+		for _, line := range strings.Split(strings.TrimRight(text, "\n"), "\n") {
+			if strings.TrimSpace(line) != "" {
+				fmt.Printf("%s | %s\n", name, line)
+			}
+		}
+	}
+}
+
+// InspectImage looks up an image locally, reporting whether it exists.
+func (b *DockerBackend) InspectImage(ctx context.Context, ref string) (*Image, bool, error) {
+	inspect, _, err := b.cli.ImageInspectWithRaw(ctx, ref)
+	if client.IsErrNotFound(err) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	image := &Image{ID: inspect.ID}
+	if inspect.Config != nil {
+		image.Labels = inspect.Config.Labels
+	}
+	return image, true, nil
+}
+
+// CreateContainer creates a container that joins the network of the spec, its ports bound to the loopback interface.
+func (b *DockerBackend) CreateContainer(ctx context.Context, spec ContainerSpec) (string, error) {
+	// Bind the requested ports of the container to the host.
+	var portSpecs []string
+	for _, port := range spec.Ports {
+		portSpecs = append(portSpecs, fmt.Sprintf("127.0.0.1::%d", port))
+	}
+	exposedPorts, portBindings, err := nat.ParsePortSpecs(portSpecs)
+	if err != nil {
+		return "", err
+	}
+	hostConfig := &container.HostConfig{
+		AutoRemove:   spec.AutoRemove,
+		PortBindings: portBindings,
+	}
+
+	// Make the container join the specified network when run.
+	networkConfig := &network.NetworkingConfig{
+		EndpointsConfig: map[string]*network.EndpointSettings{spec.Network: {Aliases: spec.Aliases}},
+	}
+
+	containerConfig := &container.Config{
+		Image:        spec.Image,
+		Env:          spec.Env,
+		ExposedPorts: exposedPorts,
+		Labels:       spec.Labels,
+	}
+	cont, err := b.cli.ContainerCreate(ctx, containerConfig, hostConfig, networkConfig, nil, spec.Name)
+	if err != nil {
+		return "", err
+	}
+	return cont.ID, nil
+}
+
+// StartContainer starts a created container.
+func (b *DockerBackend) StartContainer(ctx context.Context, id string) error {
+	return b.cli.ContainerStart(ctx, id, types.ContainerStartOptions{})
+}
+
+// RemoveContainer removes a container, killing it if it is running.
+func (b *DockerBackend) RemoveContainer(ctx context.Context, id string) error {
+	return b.cli.ContainerRemove(ctx, id, types.ContainerRemoveOptions{Force: true})
+}
+
+// SignalContainer sends a signal to the main process of a container.
+func (b *DockerBackend) SignalContainer(ctx context.Context, id string, signal string) error {
+	return b.cli.ContainerKill(ctx, id, signal)
+}
+
+// StopContainer sends a signal to a container and kills it if it hasn't exited within the grace period.
+func (b *DockerBackend) StopContainer(ctx context.Context, id string, signal string, grace time.Duration) error {
+	waitCtx, cancel := context.WithTimeout(ctx, grace)
+	defer cancel()
+
+	// Start waiting before the signal is sent so that the exit can't be missed.
+	exited, errs := b.cli.ContainerWait(waitCtx, id, container.WaitConditionNotRunning)
+	if err := b.cli.ContainerKill(ctx, id, signal); err != nil {
+		return err
+	}
+
+	select {
+	case <-exited:
+		return nil
+	case err := <-errs:
+		if ctx.Err() == nil && waitCtx.Err() != nil {
+			// The grace period is over.
+			return b.cli.ContainerKill(ctx, id, "SIGKILL")
+		}
+		return err
+	}
+}
+
+// Exec runs a command inside a container and waits for it to exit.
+// The output of the command is discarded.
+func (b *DockerBackend) Exec(ctx context.Context, id string, command []string) (int, error) {
+	exec, err := b.cli.ContainerExecCreate(ctx, id, types.ExecConfig{
+		AttachStdout: true,
+		AttachStderr: true,
+		Cmd:          command,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	attach, err := b.cli.ContainerExecAttach(ctx, exec.ID, types.ExecStartCheck{})
+	if err != nil {
+		return 0, err
+	}
+	defer attach.Close()
+
+	// The hijacked connection doesn't observe the context, so close it when the context is done.
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			attach.Close()
+		case <-done:
+		}
+	}()
+
+	// The output stream ends when the command exits.
+	if _, err := io.Copy(io.Discard, attach.Reader); err != nil {
+		return 0, err
+	}
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	inspect, err := b.cli.ContainerExecInspect(ctx, exec.ID)
+	if err != nil {
+		return 0, err
+	}
+	return inspect.ExitCode, nil
+}
+
+// Logs demultiplexes the output of a container, which is never run with a TTY, into a single stream.
+func (b *DockerBackend) Logs(ctx context.Context, id string, lines int) (string, error) {
+	res, err := b.cli.ContainerLogs(ctx, id, types.ContainerLogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Tail:       strconv.Itoa(lines),
+	})
+	if err != nil {
+		return "", err
+	}
+	defer res.Close()
+
+	var logs strings.Builder
+	if _, err := stdcopy.StdCopy(&logs, &logs, res); err != nil {
+		return "", err
+	}
+	return logs.String(), nil
+}
+
+// StreamLogs sends the timestamped lines of the output of a container until they end or the context is done.
+func (b *DockerBackend) StreamLogs(ctx context.Context, id string, options LogOptions) (<-chan LogLine, <-chan error) {
+	lines := make(chan LogLine)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(lines)
+
+		logOptions := types.ContainerLogsOptions{
+			ShowStdout: true,
+			ShowStderr: true,
+			Timestamps: true,
+			Follow:     options.Follow,
+			Tail:       "all",
+		}
+		if options.Tail >= 0 {
+			logOptions.Tail = strconv.Itoa(options.Tail)
+		}
+		if !options.Since.IsZero() {
+			logOptions.Since = options.Since.Format(time.RFC3339Nano)
+		}
+		if !options.Until.IsZero() {
+			logOptions.Until = options.Until.Format(time.RFC3339Nano)
+		}
+		res, err := b.cli.ContainerLogs(ctx, id, logOptions)
+		if err != nil {
+			errs <- err
+			return
+		}
+		defer res.Close()
+
+		// Demultiplex stdout and stderr into a single stream of lines.
+		reader, writer := io.Pipe()
+		defer reader.Close()
+		go func() {
+			_, err := stdcopy.StdCopy(writer, writer, res)
+			writer.CloseWithError(err)
+		}()
+
+		scanner := bufio.NewScanner(reader)
+		for scanner.Scan() {
+			select {
+			case lines <- parseLogLine(scanner.Text()):
+			case <-ctx.Done():
+				return
+			}
+		}
+		if err := scanner.Err(); err != nil && ctx.Err() == nil {
+			errs <- err
+		}
+	}()
+
+	return lines, errs
+}
+
+// parseLogLine splits the timestamp that Docker adds to each line of the logs from the text.
+func parseLogLine(line string) LogLine {
+	timestamp, text, _ := strings.Cut(line, " ")
+	t, err := time.Parse(time.RFC3339Nano, timestamp)
+	if err != nil {
+		return LogLine{Text: line}
+	}
+	return LogLine{Time: t, Text: text}
+}
+
+// InspectContainer returns the state, settings and host ports of a container.
+func (b *DockerBackend) InspectContainer(ctx context.Context, id string) (*Container, error) {
+	inspect, err := b.cli.ContainerInspect(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Container{
+		ID:        inspect.ID,
+		Name:      strings.TrimPrefix(inspect.Name, "/"),
+		Image:     inspect.Config.Image,
+		Labels:    inspect.Config.Labels,
+		Running:   inspect.State != nil && inspect.State.Running,
+		HostPorts: map[int]string{},
+	}
+	if inspect.State != nil {
+		c.StartedAt, _ = time.Parse(time.RFC3339Nano, inspect.State.StartedAt)
+		c.ExitCode = inspect.State.ExitCode
+		c.OOMKilled = inspect.State.OOMKilled
+		if inspect.State.Health != nil {
+			c.Health = inspect.State.Health.Status
+		}
+	}
+	if inspect.NetworkSettings != nil {
+		for name := range inspect.NetworkSettings.Networks {
+			c.Networks = append(c.Networks, name)
+		}
+		for port, bindings := range inspect.NetworkSettings.Ports {
+			if port.Proto() == "tcp" && len(bindings) > 0 {
+				c.HostPorts[port.Int()] = bindings[0].HostPort
+			}
+		}
+	}
+	return c, nil
+}
+
+// ListContainers returns the containers matching the options, including stopped ones if requested.
+func (b *DockerBackend) ListContainers(ctx context.Context, options ListOptions) ([]*Container, error) {
+	args := filters.NewArgs()
+	if options.Name != "" {
+		// The name filter matches substrings, so the exact name is checked below as well.
+		args.Add("name", options.Name)
+	}
+	if options.Label != "" {
+		args.Add("label", options.Label)
+	}
+	if options.Component != "" {
+		args.Add("label", componentLabel+"="+options.Component)
+	}
+
+	list, err := b.cli.ContainerList(ctx, types.ContainerListOptions{All: options.All, Filters: args})
+	if err != nil {
+		return nil, err
+	}
+
+	containers := make([]*Container, 0, len(list))
+	for _, item := range list {
+		c := &Container{
+			ID:        item.ID,
+			Image:     item.Image,
+			Labels:    item.Labels,
+			Running:   item.State == "running",
+			HostPorts: map[int]string{},
+		}
+		if len(item.Names) > 0 {
+			c.Name = strings.TrimPrefix(item.Names[0], "/")
+		}
+		if options.Name != "" && c.Name != options.Name {
+			continue
+		}
+		if item.NetworkSettings != nil {
+			for name := range item.NetworkSettings.Networks {
+				c.Networks = append(c.Networks, name)
+			}
+		}
+		for _, port := range item.Ports {
+			if port.Type == "tcp" && port.PublicPort != 0 {
+				c.HostPorts[int(port.PrivatePort)] = fmt.Sprint(port.PublicPort)
+			}
+		}
+		containers = append(containers, c)
+	}
+	return containers, nil
+}
+
+// Events translates the Docker events of containers with the given label.
+func (b *DockerBackend) Events(ctx context.Context, label string) (<-chan ContainerEvent, <-chan error) {
+	messages, errs := b.cli.Events(ctx, types.EventsOptions{
+		Filters: filters.NewArgs(
+			filters.Arg("type", "container"),
+			filters.Arg("label", label),
+			filters.Arg("event", EventStart),
+			filters.Arg("event", EventDie),
+			filters.Arg("event", EventOOM),
+			filters.Arg("event", EventKill),
+			filters.Arg("event", EventDestroy),
+		),
+	})
+
+	events := make(chan ContainerEvent)
+	failed := make(chan error, 1)
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case err := <-errs:
+				failed <- err
+				return
+			case message := <-messages:
+				event := ContainerEvent{
+					ContainerID: message.Actor.ID,
+					Action:      message.Action,
+					Signal:      message.Actor.Attributes["signal"],
+				}
+				event.ExitCode, _ = strconv.Atoi(message.Actor.Attributes["exitCode"])
+				select {
+				case events <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return events, failed
+}
@@ -0,0 +1,185 @@
+package internal
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"strings"
+)
+
+// containerConfig is the fingerprint of the settings a container of a component is created with.
+// It maps each setting to a hash of its value, so that the settings that changed can be told apart.
+type containerConfig map[string]string
+
+// configFields are the settings in a containerConfig.
+var configFields = []string{"image", "env", "ports", "network"}
+
+// hashValue returns a short hash of a setting.
+func hashValue(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:8])
+}
+
+// desiredConfig returns the fingerprint of the settings the container of a component should be created with,
+// and records it on the component. The build context of a component that is built is hashed for it, and its hash is
+// returned as well.
+func (mw *Millwright) desiredConfig(ctx context.Context, component *Component) (containerConfig, string, error) {
+	runConfig := component.runConfig
+
+	image := runConfig.Image
+	var buildHash string
+	if image == "" {
+		hash, err := hashBuildContext(runConfig.BuildContextPath, runConfig.DockerfilePath)
+		if err != nil {
+			return nil, "", err
+		}
+		buildHash = hash
+		image = "build:" + hash
+	}
+
+	config := containerConfig{
+		"image":   hashValue(image),
+		"env":     hashValue(strings.Join(runConfig.Env, "\n")),
+		"ports":   hashValue(fmt.Sprint(component.boundPorts(ctx.Value(introspectionPortKey).(int)))),
+		"network": hashValue(ctx.Value(networkNameKey).(string)),
+	}
+	component.setFingerprint(config, buildHash)
+	return config, buildHash, nil
+}
+
+// hash combines the hashes of all the settings.
+func (c containerConfig) hash() string {
+	fields := make([]string, 0, len(c))
+	for _, field := range sortedKeys(c) {
+		fields = append(fields, field+"="+c[field])
+	}
+	return hashValue(strings.Join(fields, ","))
+}
+
+// labels returns the labels that record the fingerprint on a container.
+func (c containerConfig) labels() map[string]string {
+	labels := map[string]string{configHashLabel: c.hash()}
+	for field, hash := range c {
+		labels[configHashLabel+"."+field] = hash
+	}
+	return labels
+}
+
+// drift returns the settings in which a container with the given labels differs from the fingerprint.
+// Containers that were created without a fingerprint differ in all of them.
+func (c containerConfig) drift(labels map[string]string) []string {
+	if labels[configHashLabel] == c.hash() {
+		return nil
+	}
+
+	var changed []string
+	for _, field := range configFields {
+		if labels[configHashLabel+"."+field] != c[field] {
+			changed = append(changed, field)
+		}
+	}
+	if len(changed) == 0 {
+		return configFields
+	}
+	return changed
+}
+
+// configDrift returns the settings in which the container with the given ID differs from a fingerprint.
+func (mw *Millwright) configDrift(ctx context.Context, config containerConfig, id string) ([]string, error) {
+	container, err := mw.backend.InspectContainer(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return config.drift(container.Labels), nil
+}
+
+// recordStart records the settings a container of the project was started with, so that the next round of
+// checkDrift can tell whether it has drifted, e.g. because a millwright with an older configuration replaced it.
+func (mw *Millwright) recordStart(ctx context.Context, id string) {
+	container, err := mw.backend.InspectContainer(ctx, id)
+	if err != nil {
+		// Already gone, which the events of its death take care of.
+		return
+	}
+	for _, component := range mw.currentComponents() {
+		if component.serviceName == container.Labels[componentLabel] {
+			component.recordStart(container)
+		}
+	}
+}
+
+// checkDrift recreates the started components whose containers were started with settings other than the ones they
+// should have, by comparing the fingerprint of each component with the labels of its container as recorded by
+// recordStart. Changes to the configuration are picked up by Reload instead.
+// The components are recreated one at a time in dependency order, each once the previous one is ready, with the
+// settings they were launched with. Only one round of recreations runs at a time.
+func (mw *Millwright) checkDrift(ctx context.Context) {
+	if !mw.beginRecreate() {
+		return
+	}
+
+	drifted := map[*Component]string{}
+	var order []*Component
+	for _, component := range topologicalOrder(mw.currentComponents()) {
+		status := component.currentStatus()
+		config, _ := component.currentFingerprint()
+		container := component.lastStarted()
+		if (status != Starting && status != Ready) || config == nil || container == nil {
+			continue
+		}
+		if changed := config.drift(container.Labels); len(changed) > 0 {
+			log.Infof(
+				"Container of %s has drifted from its configuration (%s), recreating it.",
+				component.serviceName, strings.Join(changed, ", "),
+			)
+			drifted[component] = container.ID
+			order = append(order, component)
+		}
+	}
+	if len(order) == 0 {
+		mw.endRecreate()
+		return
+	}
+
+	mw.handlers.Add(1)
+	go func() {
+		defer mw.handlers.Done()
+		defer mw.endRecreate()
+		for _, component := range order {
+			// The drifted container is the one that is replaced, even if it was started behind the back of the
+			// component.
+			component.setContainer(drifted[component])
+			mw.restartWith(ctx, component, mw.recreateContainer)
+		}
+	}()
+}
+
+// recreateContainer relaunches a component with the settings it was launched with, without hashing its build
+// context again.
+func (mw *Millwright) recreateContainer(ctx context.Context, component *Component) (string, error) {
+	config, buildHash := component.currentFingerprint()
+	return mw.replaceContainer(ctx, component, config, buildHash)
+}
+
+// beginRecreate marks that drifted components are being recreated.
+// It returns false if they already are.
+func (mw *Millwright) beginRecreate() bool {
+	mw.mu.Lock()
+	defer mw.mu.Unlock()
+
+	if mw.recreating {
+		return false
+	}
+	mw.recreating = true
+	return true
+}
+
+// endRecreate marks that drifted components are no longer being recreated.
+func (mw *Millwright) endRecreate() {
+	mw.mu.Lock()
+	defer mw.mu.Unlock()
+
+	mw.recreating = false
+}
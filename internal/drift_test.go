@@ -0,0 +1,120 @@
+package internal
+
+import (
+	"github.com/sirupsen/logrus/hooks/test"
+	"strings"
+	"testing"
+)
+
+func TestContainerConfigDrift(t *testing.T) {
+	config := containerConfig{"image": "a", "env": "b", "ports": "c", "network": "d"}
+	if changed := config.drift(config.labels()); changed != nil {
+		t.Errorf("Container with the same config has drifted in %v.", changed)
+	}
+
+	labels := config.labels()
+	labels[configHashLabel] = "outdated"
+	labels[configHashLabel+".env"] = "outdated"
+	if changed := config.drift(labels); strings.Join(changed, ",") != "env" {
+		t.Errorf("Expected the env to have changed, got %v.", changed)
+	}
+
+	if changed := config.drift(map[string]string{}); len(changed) != len(configFields) {
+		t.Errorf("Container without a config hash should differ in all fields, got %v.", changed)
+	}
+}
+
+func TestStartRecreatesDriftedComponents(t *testing.T) {
+	backend := newFakeBackend()
+	defer backend.close()
+
+	if err := testMillwright(t, backend).Start(testContext(t)); err != nil {
+		t.Fatal(err)
+	}
+	creates := len(backend.creates)
+
+	hook := test.NewGlobal()
+	defer hook.Reset()
+
+	// A new millwright with a changed configuration should only recreate the components that changed,
+	// dependencies first.
+	mw := testMillwright(t, backend)
+	for _, component := range mw.components {
+		if component.serviceName == "ingestion" || component.serviceName == "handler_cpu" {
+			component.runConfig.Env = append(component.runConfig.Env, "CHANGED=1")
+		}
+	}
+	if err := mw.Start(testContext(t)); err != nil {
+		t.Fatal(err)
+	}
+
+	if launched := backend.creates[creates:]; strings.Join(launched, ",") != "test-ingestion,test-handler_cpu" {
+		t.Fatalf("Expected ingestion and then handler_cpu to be recreated, launches: %v.", launched)
+	}
+	var explained bool
+	for _, entry := range hook.AllEntries() {
+		explained = explained || entry.Message == "Configuration of ingestion has changed (env), recreating it."
+	}
+	if !explained {
+		t.Error("The changed fields weren't logged.")
+	}
+}
+
+func TestReconcileRecreatesDriftedContainers(t *testing.T) {
+	backend := newFakeBackend()
+	defer backend.close()
+	mw := testMillwright(t, backend)
+	mw.timing = fastTiming
+	ctx := testContext(t)
+
+	if err := mw.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer reconcile(ctx, mw)()
+	waitFor(t, "the events to be watched", backend.watched)
+
+	// The containers of handler_cpu and of its dependency ingestion are started with the settings of a millwright
+	// with another configuration, dependent first.
+	mw.setPaused(true)
+	drifted := map[*Component]string{}
+	for _, component := range []*Component{mw.components[0], mw.components[2]} {
+		backend.mu.Lock()
+		c := backend.find(component.serviceName)
+		labels := map[string]string{}
+		for key, value := range c.Labels {
+			labels[key] = value
+		}
+		labels[configHashLabel] = "outdated"
+		labels[configHashLabel+".image"] = "outdated"
+		c.Labels = labels
+		drifted[component] = c.ID
+		backend.emit(c, ContainerEvent{Action: EventStart})
+		backend.mu.Unlock()
+	}
+	waitFor(t, "the starts to be recorded", func() bool {
+		for component := range drifted {
+			if started := component.lastStarted(); started == nil || started.Labels[configHashLabel] != "outdated" {
+				return false
+			}
+		}
+		return true
+	})
+	creates := len(backend.creates)
+	mw.setPaused(false)
+
+	for component, id := range drifted {
+		waitFor(t, component.serviceName+" to be recreated", func() bool {
+			current, _ := component.container()
+			return current != id && component.currentStatus() == Ready
+		})
+		config, _ := component.currentFingerprint()
+		if labels := backend.container(component.serviceName).Labels; labels[configHashLabel] != config.hash() {
+			t.Errorf("Recreated container of %s has the config hash %s.", component.serviceName, labels[configHashLabel])
+		}
+	}
+	// Dependencies are recreated before their dependents.
+	recreated := strings.Join(backend.creates[creates:], ",")
+	if recreated != "test-ingestion,test-handler_cpu" {
+		t.Errorf("Unexpected recreations: %s.", recreated)
+	}
+}
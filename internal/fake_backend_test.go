@@ -0,0 +1,522 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// fakeBackend is an in-memory Backend.
+// Bound ports are simulated with real HTTP servers on the loopback interface,
+// so that heartbeats reach the "container" like they would with Docker.
+type fakeBackend struct {
+	mu         sync.Mutex
+	nextID     int
+	networks   map[string]string // name -> ID
+	images     map[string]*Image
+	registry   map[string]bool           // images that can be pulled
+	containers map[string]*fakeContainer // ID -> container
+	statuses   map[string]int            // HTTP status new containers respond with by component, defaults to 200
+	watchers   []fakeWatcher             // subscribers of Events
+	buildTime  time.Duration             // time each build takes
+
+	building    int // builds in progress
+	maxBuilding int // most builds that were in progress at the same time
+
+	builds  []string // tags of all the images built, in order
+	pulls   []string // references of all the images pulled, in order
+	creates []string // names of all the containers created, in order
+	signals []string // signals sent to containers in NAME:SIGNAL format, in order
+	stops   []string // containers stopped in NAME:SIGNAL format, in order
+}
+
+type fakeContainer struct {
+	Container
+	spec     ContainerSpec
+	servers  map[int]*httptest.Server // container port -> server bound to the host port
+	status   int32                    // HTTP status the servers respond with, accessed atomically
+	delay    int64                    // time the servers take to respond, accessed atomically
+	exitCode int                      // exit code of commands executed in the container
+	logs     []LogLine                // lines of output of the container
+}
+
+type fakeWatcher struct {
+	ctx    context.Context
+	label  string
+	events chan ContainerEvent
+}
+
+var _ Backend = (*fakeBackend)(nil)
+
+func newFakeBackend() *fakeBackend {
+	return &fakeBackend{
+		networks:   map[string]string{},
+		images:     map[string]*Image{},
+		registry:   map[string]bool{},
+		containers: map[string]*fakeContainer{},
+		statuses:   map[string]int{},
+	}
+}
+
+func (b *fakeBackend) newID() string {
+	b.nextID++
+	return fmt.Sprintf("id%d", b.nextID)
+}
+
+func (b *fakeBackend) ListNetworks(_ context.Context, name string) ([]string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var ids []string
+	for n, id := range b.networks {
+		if n == name {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+func (b *fakeBackend) CreateNetwork(_ context.Context, name string, _ map[string]string) (string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	id := b.newID()
+	b.networks[name] = id
+	return id, nil
+}
+
+func (b *fakeBackend) BuildImage(ctx context.Context, options BuildOptions) error {
+	b.mu.Lock()
+	b.building++
+	if b.building > b.maxBuilding {
+		b.maxBuilding = b.building
+	}
+	buildTime := b.buildTime
+	b.mu.Unlock()
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(buildTime):
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.building--
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	b.images[options.Tag] = &Image{ID: b.newID(), Labels: options.Labels}
+	b.builds = append(b.builds, options.Tag)
+	return nil
+}
+
+func (b *fakeBackend) PullImage(_ context.Context, ref string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.registry[ref] {
+		return fmt.Errorf("pull access denied for %s", ref)
+	}
+	b.images[ref] = &Image{ID: b.newID()}
+	b.pulls = append(b.pulls, ref)
+	return nil
+}
+
+func (b *fakeBackend) InspectImage(_ context.Context, ref string) (*Image, bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	image, ok := b.images[ref]
+	return image, ok, nil
+}
+
+func (b *fakeBackend) CreateContainer(_ context.Context, spec ContainerSpec) (string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.images[spec.Image]; !ok {
+		return "", fmt.Errorf("no such image: %s", spec.Image)
+	}
+	for _, c := range b.containers {
+		if c.Name == spec.Name {
+			return "", fmt.Errorf("conflict: container name %s is already in use", spec.Name)
+		}
+	}
+
+	status, ok := b.statuses[spec.Labels[componentLabel]]
+	if !ok {
+		status = http.StatusOK
+	}
+
+	id := b.newID()
+	b.containers[id] = &fakeContainer{
+		Container: Container{
+			ID:        id,
+			Name:      spec.Name,
+			Image:     spec.Image,
+			Labels:    spec.Labels,
+			Networks:  []string{spec.Network},
+			HostPorts: map[int]string{},
+		},
+		spec:    spec,
+		servers: map[int]*httptest.Server{},
+		status:  int32(status),
+	}
+	b.creates = append(b.creates, spec.Name)
+	return id, nil
+}
+
+func (b *fakeBackend) StartContainer(_ context.Context, id string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c, ok := b.containers[id]
+	if !ok {
+		return fmt.Errorf("no such container: %s", id)
+	}
+	c.Running = true
+	c.StartedAt = time.Now()
+	for _, port := range c.spec.Ports {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-time.After(time.Duration(atomic.LoadInt64(&c.delay))):
+			case <-r.Context().Done():
+				return
+			}
+			w.WriteHeader(int(atomic.LoadInt32(&c.status)))
+			_, _ = w.Write([]byte(`{"status": "ok"}`))
+		}))
+		_, hostPort, _ := net.SplitHostPort(server.Listener.Addr().String())
+		c.servers[port] = server
+		c.HostPorts[port] = hostPort
+	}
+	b.emit(c, ContainerEvent{Action: EventStart})
+	return nil
+}
+
+func (b *fakeBackend) RemoveContainer(_ context.Context, id string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := b.find(id)
+	if c == nil {
+		return fmt.Errorf("no such container: %s", id)
+	}
+	if c.Running {
+		b.emit(c, ContainerEvent{Action: EventKill, Signal: "SIGKILL"})
+		b.emit(c, ContainerEvent{Action: EventDie, ExitCode: 137})
+	}
+	c.stop()
+	delete(b.containers, c.ID)
+	b.emit(c, ContainerEvent{Action: EventDestroy})
+	return nil
+}
+
+func (b *fakeBackend) SignalContainer(_ context.Context, id string, signal string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := b.find(id)
+	if c == nil || !c.Running {
+		return fmt.Errorf("container %s is not running", id)
+	}
+	b.signals = append(b.signals, c.Name+":"+signal)
+	b.emit(c, ContainerEvent{Action: EventKill, Signal: signal})
+	return nil
+}
+
+func (b *fakeBackend) StopContainer(_ context.Context, id string, signal string, _ time.Duration) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := b.find(id)
+	if c == nil || !c.Running {
+		return fmt.Errorf("container %s is not running", id)
+	}
+	b.stops = append(b.stops, c.Name+":"+signal)
+	b.emit(c, ContainerEvent{Action: EventKill, Signal: signal})
+	b.emit(c, ContainerEvent{Action: EventDie, ExitCode: 143})
+	c.stop()
+	return nil
+}
+
+func (b *fakeBackend) Exec(_ context.Context, id string, _ []string) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := b.find(id)
+	if c == nil || !c.Running {
+		return 0, fmt.Errorf("container %s is not running", id)
+	}
+	return c.exitCode, nil
+}
+
+func (b *fakeBackend) Logs(_ context.Context, id string, lines int) (string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := b.find(id)
+	if c == nil {
+		return "", fmt.Errorf("no such container: %s", id)
+	}
+	tail := c.logs
+	if len(tail) > lines {
+		tail = tail[len(tail)-lines:]
+	}
+	texts := make([]string, 0, len(tail))
+	for _, line := range tail {
+		texts = append(texts, line.Text)
+	}
+	return strings.Join(texts, "\n"), nil
+}
+
+func (b *fakeBackend) StreamLogs(ctx context.Context, id string, options LogOptions) (<-chan LogLine, <-chan error) {
+	lines := make(chan LogLine, 100)
+	errs := make(chan error, 1)
+
+	b.mu.Lock()
+	c := b.find(id)
+	b.mu.Unlock()
+	if c == nil {
+		errs <- fmt.Errorf("no such container: %s", id)
+		close(lines)
+		return lines, errs
+	}
+
+	go func() {
+		defer close(lines)
+
+		// Lines written after the call are only streamed when following.
+		b.mu.Lock()
+		history := append([]LogLine{}, c.logs...)
+		b.mu.Unlock()
+
+		var selected []LogLine
+		for _, line := range history {
+			if (options.Since.IsZero() || line.Time.After(options.Since)) &&
+				(options.Until.IsZero() || line.Time.Before(options.Until)) {
+				selected = append(selected, line)
+			}
+		}
+		if options.Tail >= 0 && len(selected) > options.Tail {
+			selected = selected[len(selected)-options.Tail:]
+		}
+		for _, line := range selected {
+			select {
+			case lines <- line:
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		// Poll for new lines while following.
+		for seen := len(history); options.Follow; {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(5 * time.Millisecond):
+			}
+			b.mu.Lock()
+			fresh := append([]LogLine{}, c.logs[seen:]...)
+			b.mu.Unlock()
+			seen += len(fresh)
+			for _, line := range fresh {
+				select {
+				case lines <- line:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return lines, errs
+}
+
+func (b *fakeBackend) InspectContainer(_ context.Context, id string) (*Container, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := b.find(id)
+	if c == nil {
+		return nil, fmt.Errorf("no such container: %s", id)
+	}
+	return c.snapshot(), nil
+}
+
+func (b *fakeBackend) ListContainers(_ context.Context, options ListOptions) ([]*Container, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var list []*Container
+	for _, c := range b.containers {
+		if !c.Running && !options.All {
+			continue
+		}
+		if options.Name != "" && c.Name != options.Name {
+			continue
+		}
+		if options.Component != "" && c.Labels[componentLabel] != options.Component {
+			continue
+		}
+		if options.Label != "" {
+			key, value, _ := strings.Cut(options.Label, "=")
+			if c.Labels[key] != value {
+				continue
+			}
+		}
+		list = append(list, c.snapshot())
+	}
+	return list, nil
+}
+
+func (b *fakeBackend) Events(ctx context.Context, label string) (<-chan ContainerEvent, <-chan error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	events := make(chan ContainerEvent, 100)
+	b.watchers = append(b.watchers, fakeWatcher{ctx: ctx, label: label, events: events})
+	return events, make(chan error)
+}
+
+// watched checks whether anyone is subscribed to Events.
+func (b *fakeBackend) watched() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return len(b.watchers) > 0
+}
+
+// emit sends an event of a container to the watchers of its label.
+func (b *fakeBackend) emit(c *fakeContainer, event ContainerEvent) {
+	event.ContainerID = c.ID
+	for _, watcher := range b.watchers {
+		key, value, _ := strings.Cut(watcher.label, "=")
+		if watcher.ctx.Err() != nil || c.Labels[key] != value {
+			continue
+		}
+		select {
+		case watcher.events <- event:
+		default:
+		}
+	}
+}
+
+// find returns the container with the given ID or name, or the container of the component with the given name, or nil.
+func (b *fakeBackend) find(idOrName string) *fakeContainer {
+	if c, ok := b.containers[idOrName]; ok {
+		return c
+	}
+	for _, c := range b.containers {
+		if c.Name == idOrName || c.Labels[componentLabel] == idOrName {
+			return c
+		}
+	}
+	return nil
+}
+
+// crash simulates the container of a component panicking and exiting with code 2.
+func (b *fakeBackend) crash(name string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := b.find(name)
+	c.logs = append(c.logs, LogLine{Time: time.Now(), Text: "panic: " + name + " crashed"})
+	c.ExitCode = 2
+	c.stop()
+	b.emit(c, ContainerEvent{Action: EventDie, ExitCode: 2})
+	if c.spec.AutoRemove {
+		delete(b.containers, c.ID)
+	}
+}
+
+// respond makes the servers of a container respond with the given HTTP status,
+// and the commands executed in it exit with the given code.
+func (b *fakeBackend) respond(name string, status int, exitCode int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := b.find(name)
+	atomic.StoreInt32(&c.status, int32(status))
+	c.exitCode = exitCode
+}
+
+// stall makes the servers of a container take the given time to respond.
+func (b *fakeBackend) stall(name string, delay time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := b.find(name)
+	atomic.StoreInt64(&c.delay, int64(delay))
+}
+
+// write adds a line to the output of the container with the given name.
+func (b *fakeBackend) write(name string, text string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := b.find(name)
+	c.logs = append(c.logs, LogLine{Time: time.Now(), Text: text})
+}
+
+// disconnect simulates a container being detached from its network, which unbinds its ports.
+func (b *fakeBackend) disconnect(name string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := b.find(name)
+	for port, server := range c.servers {
+		server.Close()
+		delete(c.servers, port)
+		delete(c.HostPorts, port)
+	}
+	c.Networks = nil
+}
+
+// container returns the current state of the container with the given name, or nil.
+func (b *fakeBackend) container(name string) *Container {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if c := b.find(name); c != nil {
+		return c.snapshot()
+	}
+	return nil
+}
+
+// close shuts down the servers of all containers.
+func (b *fakeBackend) close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, c := range b.containers {
+		c.stop()
+	}
+}
+
+func (c *fakeContainer) stop() {
+	c.Running = false
+	for port, server := range c.servers {
+		server.Close()
+		delete(c.servers, port)
+		delete(c.HostPorts, port)
+	}
+}
+
+func (c *fakeContainer) snapshot() *Container {
+	s := c.Container
+	s.HostPorts = map[int]string{}
+	for port, hostPort := range c.HostPorts {
+		s.HostPorts[port] = hostPort
+	}
+	s.Networks = append([]string{}, c.Networks...)
+	return &s
+}
@@ -0,0 +1,25 @@
+package internal
+
+// topologicalOrder returns the components ordered so that every component comes after its dependencies.
+// Components keep their relative order otherwise. The dependency graph is expected to be acyclic.
+func topologicalOrder(components []*Component) []*Component {
+	visited := make(map[*Component]bool, len(components))
+	order := make([]*Component, 0, len(components))
+
+	var visit func(component *Component)
+	visit = func(component *Component) {
+		if visited[component] {
+			return
+		}
+		visited[component] = true
+		for _, dependency := range component.dependencies {
+			visit(dependency)
+		}
+		order = append(order, component)
+	}
+
+	for _, component := range components {
+		visit(component)
+	}
+	return order
+}
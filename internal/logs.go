@@ -0,0 +1,205 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// logColors are the ANSI colors that the prefixes of the lines of different components cycle through.
+var logColors = []string{"36", "33", "32", "35", "34", "31"}
+
+// logSource is a container whose logs are shown under the name of its component.
+type logSource struct {
+	name string
+	id   string
+}
+
+// logEntry is a line of the logs of a component.
+type logEntry struct {
+	name string
+	line LogLine
+}
+
+// logWriter writes lines of logs prefixed with the name of their component.
+type logWriter struct {
+	out      io.Writer
+	width    int               // length of the longest name, so that the lines are aligned
+	prefixes map[string]string // name -> prefix
+}
+
+// newLogWriter is a factory method for logWriter.
+// If color is set, the prefix of each component gets its own color.
+func newLogWriter(out io.Writer, sources []logSource, color bool) *logWriter {
+	w := &logWriter{out: out, prefixes: map[string]string{}}
+	for _, source := range sources {
+		if len(source.name) > w.width {
+			w.width = len(source.name)
+		}
+	}
+	for i, source := range sources {
+		prefix := fmt.Sprintf("%-*s |", w.width, source.name)
+		if color {
+			prefix = fmt.Sprintf("\x1b[%sm%s\x1b[0m", logColors[i%len(logColors)], prefix)
+		}
+		w.prefixes[source.name] = prefix
+	}
+	return w
+}
+
+// write writes a line of logs.
+func (w *logWriter) write(entry logEntry) {
+	fmt.Fprintf(w.out, "%s %s\n", w.prefixes[entry.name], entry.line.Text)
+}
+
+// ShowLogs writes the logs of the components of the given project with the given names, or of all of its components
+// if no names are given, to out.
+func ShowLogs(ctx context.Context, project *Project, names []string, options LogOptions, color bool, out io.Writer) error {
+	mw, ctx, err := newProjectMillwright(ctx, project, Options{})
+	if err != nil {
+		return err
+	}
+	return mw.Logs(ctx, names, options, color, out)
+}
+
+// Logs writes the logs of the containers of the components with the given names, or of all components if no names
+// are given, to out. The lines of different components are interleaved by the time they were written, and are
+// prefixed with the name of their component.
+// When following the logs, new lines are written as they arrive until the context is done.
+func (mw *Millwright) Logs(ctx context.Context, names []string, options LogOptions, color bool, out io.Writer) error {
+	selected, err := mw.selectComponents(names)
+	if err != nil {
+		return err
+	}
+
+	containers, err := mw.backend.ListContainers(ctx, ListOptions{
+		Label: projectFilter(ctx),
+		All:   true,
+	})
+	if err != nil {
+		return err
+	}
+	byComponent, _ := mw.containersByComponent(containers)
+
+	var sources []logSource
+	for _, component := range mw.currentComponents() {
+		if !selected[component] {
+			continue
+		}
+		container, ok := byComponent[component.serviceName]
+		if !ok {
+			log.Warnf("%s has no container.", component.serviceName)
+			continue
+		}
+		sources = append(sources, logSource{name: component.serviceName, id: container.ID})
+	}
+	if len(sources) == 0 {
+		return errors.New("none of the components have a container")
+	}
+	w := newLogWriter(out, sources, color)
+
+	// Interleave the lines that were already written, up to the point from which they're followed.
+	history := options
+	history.Follow = false
+	followSince := time.Now()
+	if options.Follow {
+		history.Until = followSince
+	}
+	entries, err := mw.collectLogs(ctx, sources, history)
+	if err != nil {
+		return err
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].line.Time.Before(entries[j].line.Time)
+	})
+	for _, entry := range entries {
+		w.write(entry)
+	}
+
+	if !options.Follow {
+		return nil
+	}
+	return mw.followLogs(ctx, sources, LogOptions{Follow: true, Tail: -1, Since: followSince}, w)
+}
+
+// collectLogs reads the logs of the given containers concurrently.
+func (mw *Millwright) collectLogs(ctx context.Context, sources []logSource, options LogOptions) ([]logEntry, error) {
+	var mu sync.Mutex
+	var entries []logEntry
+	var firstErr error
+
+	var wg sync.WaitGroup
+	for _, source := range sources {
+		wg.Add(1)
+		go func(source logSource) {
+			defer wg.Done()
+			var read []logEntry
+			err := mw.readLogs(ctx, source, options, func(entry logEntry) {
+				read = append(read, entry)
+			})
+
+			mu.Lock()
+			defer mu.Unlock()
+			entries = append(entries, read...)
+			if err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}(source)
+	}
+	wg.Wait()
+
+	return entries, firstErr
+}
+
+// followLogs writes the lines of the given containers as they arrive, until the context is done or all the
+// containers have stopped.
+func (mw *Millwright) followLogs(ctx context.Context, sources []logSource, options LogOptions, w *logWriter) error {
+	merged := make(chan logEntry)
+	errs := make(chan error, len(sources))
+
+	var wg sync.WaitGroup
+	for _, source := range sources {
+		wg.Add(1)
+		go func(source logSource) {
+			defer wg.Done()
+			errs <- mw.readLogs(ctx, source, options, func(entry logEntry) {
+				merged <- entry
+			})
+		}(source)
+	}
+	go func() {
+		wg.Wait()
+		close(merged)
+		close(errs)
+	}()
+
+	for entry := range merged {
+		w.write(entry)
+	}
+	for err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readLogs passes each line of the logs of a container to handle until they end.
+func (mw *Millwright) readLogs(ctx context.Context, source logSource, options LogOptions, handle func(logEntry)) error {
+	lines, errs := mw.backend.StreamLogs(ctx, source.id, options)
+	for line := range lines {
+		handle(logEntry{name: source.name, line: line})
+	}
+
+	select {
+	case err := <-errs:
+		return fmt.Errorf("can't read the logs of %s: %v", source.name, err)
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,108 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// syncBuffer is a buffer that can be written while it's read by a test.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestLogsInterleavesComponents(t *testing.T) {
+	backend := newFakeBackend()
+	defer backend.close()
+	mw := testMillwright(t, backend)
+	ctx := testContext(t)
+
+	if err := mw.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	backend.write("ingestion", "first")
+	backend.write("handler_cpu", "second")
+	backend.write("ingestion", "third")
+	backend.write("handler_cpu", "fourth")
+
+	var out bytes.Buffer
+	if err := mw.Logs(ctx, []string{"handler_cpu", "ingestion"}, LogOptions{Tail: -1}, false, &out); err != nil {
+		t.Fatal(err)
+	}
+	expected := "ingestion   | first\n" +
+		"handler_cpu | second\n" +
+		"ingestion   | third\n" +
+		"handler_cpu | fourth\n"
+	if out.String() != expected {
+		t.Fatalf("Unexpected logs:\n%s", out.String())
+	}
+
+	out.Reset()
+	if err := mw.Logs(ctx, nil, LogOptions{Tail: 1}, false, &out); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(out.String(), "\n") != 2 || !strings.Contains(out.String(), "| third") {
+		t.Fatalf("Tail wasn't applied per component:\n%s", out.String())
+	}
+
+	if err := mw.Logs(ctx, []string{"unknown"}, LogOptions{Tail: -1}, false, &out); err == nil {
+		t.Fatal("Showing the logs of an unknown component should have failed.")
+	}
+}
+
+func TestLogsFollow(t *testing.T) {
+	backend := newFakeBackend()
+	defer backend.close()
+	mw := testMillwright(t, backend)
+	ctx := testContext(t)
+
+	if err := mw.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	backend.write("ingestion", "before")
+
+	followCtx, cancel := context.WithCancel(ctx)
+	var out syncBuffer
+	done := make(chan error)
+	go func() {
+		done <- mw.Logs(followCtx, []string{"ingestion"}, LogOptions{Follow: true, Tail: -1}, false, &out)
+	}()
+
+	waitFor(t, "the existing lines", func() bool {
+		return strings.Contains(out.String(), "ingestion | before")
+	})
+	time.Sleep(10 * time.Millisecond)
+	backend.write("ingestion", "after")
+	waitFor(t, "the new lines", func() bool {
+		return strings.Contains(out.String(), "ingestion | after")
+	})
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Following the logs didn't stop.")
+	}
+	if strings.Count(out.String(), "before") != 1 {
+		t.Fatalf("Lines were repeated:\n%s", out.String())
+	}
+}
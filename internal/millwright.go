@@ -3,155 +3,697 @@ package internal
 import (
 	"context"
 	"fmt"
-	"github.com/docker/docker/client"
 	log "github.com/sirupsen/logrus"
-	"net/http"
+	"math/rand"
+	"strings"
+	"sync"
 	"time"
 )
 
+// Defaults of the options of a millwright.
+const (
+	DefaultParallelism   = 4   // Components that may be launched at the same time, unless specified otherwise.
+	DefaultCrashLogLines = 100 // Lines of logs saved with crashes, unless specified otherwise.
+)
+
 var (
-	reconcileCycleDelay    = 500 // Delay between each round of heartbeats (ms).
-	reconcileFailedTimeout = 3   // The time between successful heartbeats required to mark a component as failed (ms).
+	probeWorkers = 8 // Components that may be checked at the same time.
+
+	restartBackoffBase  = time.Second      // Delay before the first restart of a failed component.
+	restartBackoffMax   = time.Minute      // Cap of the delay between restarts.
+	crashLoopRestarts   = 5                // Restarts within crashLoopWindow after which a component is crash looping.
+	crashLoopWindow     = 10 * time.Minute // Window in which restarts are counted.
+	crashReportLogLines = 20               // Lines of logs included in crash reports.
+	crashesKept         = 20               // Crashes that are kept per component, the older ones are deleted.
 )
 
 // Millwright takes care of configuring, executing, and monitoring the other components.
 type Millwright struct {
-	cli        *client.Client
-	components []*Component
+	backend     Backend
+	components  []*Component // replaced as a whole by Reload, read with currentComponents
+	rebuild     bool         // build images even if their build context hasn't changed
+	timing      Timing       // timing of the project, with defaults applied
+	parallelism int          // components that may be launched at the same time
+	crashDir    string       // directory crashes are saved to, crashes aren't saved if empty
+	crashLines  int          // lines of logs saved with crashes, all of them if negative
+
+	mu          sync.Mutex
+	subscribers map[chan Transition]bool // channels that receive the transitions of the components
+	handlers    sync.WaitGroup           // goroutines handling failed and drifted components
+	recreating  bool                     // drifted components are being recreated
+	changing    sync.Mutex               // held while components are restarted, stopped or reloaded on request
+	paused      bool                     // failures aren't detected nor handled, see setPaused
+	resumed     chan struct{}            // closed once monitoring is resumed after it was paused
+	missed      map[*Component]string    // containers of components that stopped while monitoring was paused
 }
 
-// NewMillwright is a factory method for Millwright.
-func NewMillwright() *Millwright {
-	mw := &Millwright{}
+// Options are the settings of a Millwright that are not part of the component configuration.
+type Options struct {
+	Rebuild     bool   // build images even if their build context hasn't changed
+	Timing      Timing // timing of the project, defaults to defaultTiming
+	Parallelism int    // components that may be launched at the same time, defaults to DefaultParallelism
+	CrashDir    string // directory crashes are saved to, crashes aren't saved if empty
+	CrashLines  int    // lines of logs saved with crashes, all of them if negative, defaults to DefaultCrashLogLines
+}
 
-	// Create Docker client
-	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
-	if err != nil {
-		log.Fatal(err)
+// NewMillwright is a factory method for Millwright.
+func NewMillwright(backend Backend, options Options) *Millwright {
+	parallelism := options.Parallelism
+	if parallelism <= 0 {
+		parallelism = DefaultParallelism
+	}
+	crashLines := options.CrashLines
+	if crashLines == 0 {
+		crashLines = DefaultCrashLogLines
 	}
-	mw.cli = cli
+	return &Millwright{
+		backend:     backend,
+		rebuild:     options.Rebuild,
+		timing:      options.Timing.withDefaults(defaultTiming),
+		parallelism: parallelism,
+		crashDir:    options.CrashDir,
+		crashLines:  crashLines,
+		subscribers: map[chan Transition]bool{},
+		missed:      map[*Component]string{},
+	}
+}
+
+// currentComponents returns the components of the configuration being monitored.
+// The slice is never modified in place, Reload replaces it.
+func (mw *Millwright) currentComponents() []*Component {
+	mw.mu.Lock()
+	defer mw.mu.Unlock()
 
-	return mw
+	return mw.components
+}
+
+// setComponents replaces the components of the configuration being monitored.
+func (mw *Millwright) setComponents(components []*Component) {
+	mw.mu.Lock()
+	defer mw.mu.Unlock()
+
+	mw.components = components
 }
 
 // Start launches all the components in the internal config.
+// Each component is launched as soon as its dependencies are ready, so independent branches of the dependency graph
+// are built and started concurrently, up to the parallelism of the millwright.
+// If a component can't be launched, the launches that are still pending are aborted and its error is returned
+// once they have all stopped.
 func (mw *Millwright) Start(ctx context.Context) error {
 	log.Info("Starting components.")
-	for _, component := range mw.components {
-		err := mw.LaunchComponentTree(ctx, component)
-		if err != nil {
-			return err
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	order := topologicalOrder(mw.currentComponents())
+	// Closed once a component has been launched, which lets its dependents wait for it to be ready.
+	launched := make(map[*Component]chan struct{}, len(order))
+	for _, component := range order {
+		launched[component] = make(chan struct{})
+	}
+	slots := make(chan struct{}, mw.parallelism)
+
+	var (
+		wg       sync.WaitGroup
+		abort    sync.Once
+		firstErr error
+	)
+	for _, component := range order {
+		if component.currentStatus() != Unstarted {
+			// Already launched by a previous start.
+			close(launched[component])
+			continue
 		}
+
+		wg.Add(1)
+		go func(component *Component) {
+			defer wg.Done()
+			if err := mw.launchWhenReady(ctx, component, launched, slots); err != nil {
+				abort.Do(func() {
+					firstErr = err
+					cancel()
+				})
+				return
+			}
+			close(launched[component])
+		}(component)
 	}
-	return nil
+	wg.Wait()
+
+	return firstErr
 }
 
-// Reconcile continuously exchanges heartbeats with each of the components in order to
-// detect potential failures.
+// Reconcile continuously exchanges heartbeats with each of the components and watches the events of their
+// containers in order to detect potential failures. Containers that have drifted from the configuration of their
+// components are recreated.
+// Heartbeats run concurrently on a pool of workers, so a component that is slow to respond doesn't delay the
+// checks of the others. While monitoring is paused, failures are neither detected nor handled.
+// Once the context is done, it returns after the failed components it was handling have been given up on.
 func (mw *Millwright) Reconcile(ctx context.Context) {
+	defer mw.handlers.Wait()
+
+	// Each component is queued at most once, so a queue with room for all of them never blocks.
+	// Once Reload adds components, the queue is replaced with a larger one, and the workers of the old one finish
+	// the checks queued on it before they return.
+	var checks chan *Component
+	var workers sync.WaitGroup
+	defer workers.Wait()
+	queueChecks := func() {
+		if size := len(mw.currentComponents()); checks == nil || cap(checks) < size {
+			if checks != nil {
+				close(checks)
+			}
+			checks = make(chan *Component, size)
+			for i := 0; i < probeWorkers; i++ {
+				workers.Add(1)
+				go func(checks <-chan *Component) {
+					defer workers.Done()
+					mw.checkWorker(ctx, checks)
+				}(checks)
+			}
+		}
+		mw.checkHealth(checks)
+	}
+
+	ticker := time.NewTicker(mw.reconcileInterval())
+	defer ticker.Stop()
+
+	label := projectFilter(ctx)
+	events, errs := mw.backend.Events(ctx, label)
+
+	queueChecks()
 	for {
-		// Return if context has been cancelled.
 		select {
 		case <-ctx.Done():
 			return
-		default:
-		}
-
-		for _, component := range mw.components {
-			if component.ignore || component.status == Failed {
-				continue
+		case event := <-events:
+			mw.handleEvent(ctx, event)
+		case err := <-errs:
+			// Heartbeats keep detecting failures until the stream is restored on the next round.
+			log.Errorf("Container events can't be received: %v", err)
+			events, errs = nil, nil
+		case <-ticker.C:
+			if events == nil {
+				events, errs = mw.backend.Events(ctx, label)
 			}
-
-			ok := mw.SendHeartbeat(component)
-			if ok {
-				component.lastSuccessfulHeartbeat = time.Now()
+			// The components may have changed since the last round.
+			ticker.Reset(mw.reconcileInterval())
+			if mw.isPaused() {
 				continue
 			}
+			mw.handleMissedFailures(ctx)
+			queueChecks()
+			mw.checkDrift(ctx)
+		}
+	}
+}
+
+// timingOf returns the timing of a component, with the timing of the project applied.
+func (mw *Millwright) timingOf(component *Component) Timing {
+	return component.timing.withDefaults(mw.timing)
+}
+
+// reconcileInterval returns the shortest interval between heartbeats of the components.
+func (mw *Millwright) reconcileInterval() time.Duration {
+	interval := mw.timing.Interval
+	for _, component := range mw.currentComponents() {
+		if componentInterval := mw.timingOf(component).Interval; componentInterval < interval {
+			interval = componentInterval
+		}
+	}
+	return interval
+}
+
+// handleEvent records why the container of a component stopped, and handles the component as failed
+// as soon as its container is gone. The settings of the containers that are started are recorded for checkDrift.
+func (mw *Millwright) handleEvent(ctx context.Context, event ContainerEvent) {
+	if event.Action == EventStart {
+		mw.recordStart(ctx, event.ContainerID)
+		return
+	}
 
-			// Check if component has been non-responsive for too long.
-			timeSinceLastSuccessfulHeartbeat := time.Now().Sub(component.lastSuccessfulHeartbeat).Milliseconds()
-			if timeSinceLastSuccessfulHeartbeat > int64(reconcileFailedTimeout) {
-				component.status = Failed
-				go mw.HandleFailedComponent(ctx, component)
+	var component *Component
+	for _, c := range mw.currentComponents() {
+		if id, _ := c.container(); id == event.ContainerID {
+			component = c
+		}
+	}
+	if component == nil {
+		// Containers that were replaced, or that belong to other configurations.
+		return
+	}
+
+	reason := component.recordEvent(event)
+	if event.Action != EventDie && event.Action != EventDestroy {
+		// The container is still around, its death follows.
+		return
+	}
+	if mw.isPaused() {
+		// Handled once monitoring is resumed, since the heartbeats don't check every component.
+		log.Infof("Container of %s stopped while monitoring is paused: %s.", component.serviceName, reason)
+		mw.mu.Lock()
+		mw.missed[component] = event.ContainerID
+		mw.mu.Unlock()
+		return
+	}
+
+	if err := mw.transition(component, Failed, Starting, Ready); err != nil {
+		// Already being handled, or stopped on purpose.
+		return
+	}
+	log.Infof("Container of %s stopped: %s.", component.serviceName, reason)
+	mw.handleFailure(ctx, component)
+}
+
+// handleMissedFailures handles the components whose containers stopped while monitoring was paused,
+// unless they have been relaunched or stopped since.
+func (mw *Millwright) handleMissedFailures(ctx context.Context) {
+	mw.mu.Lock()
+	missed := mw.missed
+	mw.missed = map[*Component]string{}
+	mw.mu.Unlock()
+
+	for component, id := range missed {
+		if current, _ := component.container(); current != id {
+			continue
+		}
+		if mw.transition(component, Failed, Starting, Ready) == nil {
+			log.Infof("Container of %s stopped while monitoring was paused.", component.serviceName)
+			mw.handleFailure(ctx, component)
+		}
+	}
+}
+
+// checkHealth queues a round of heartbeats for the components that aren't already being checked.
+func (mw *Millwright) checkHealth(checks chan<- *Component) {
+	for _, component := range mw.currentComponents() {
+		status := component.currentStatus()
+		if component.ignore || (status != Starting && status != Ready) {
+			continue
+		}
+		if component.beginCheck() {
+			checks <- component
+		}
+	}
+}
+
+// checkWorker checks the queued components until the context is done or the queue is closed.
+func (mw *Millwright) checkWorker(ctx context.Context, checks <-chan *Component) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case component, ok := <-checks:
+			if !ok {
+				return
 			}
+			mw.checkComponent(ctx, component)
+			component.endCheck()
 		}
-		time.Sleep(time.Duration(reconcileCycleDelay) * time.Millisecond)
 	}
 }
 
-// HandleFailedComponent relaunches a component that has failed and marks it as Running,
+// checkComponent runs the probes of a component that are due, and starts handling it if it has failed.
+// Components that haven't become ready yet are only handled as failed once their startup timeout has passed.
+func (mw *Millwright) checkComponent(ctx context.Context, component *Component) {
+	if component.currentStatus() == Starting {
+		mw.checkReadiness(ctx, component)
+	}
+
+	timing := mw.timingOf(component)
+	probe := component.livenessProbe(ctx.Value(introspectionPortKey).(int), timing.Interval)
+	if !component.livenessDue(probe) {
+		return
+	}
+
+	ok := mw.SendHeartbeat(ctx, component, probe)
+	failures, unresponsiveSince := component.recordLiveness(ok, probe, timing.GracePeriod)
+	if ok {
+		return
+	}
+	// Components that are still starting have until their startup timeout to answer.
+	if component.currentStatus() == Starting && time.Now().Before(component.startupDeadline()) {
+		return
+	}
+
+	// Check if component has been non-responsive for too long.
+	if failures >= probe.FailureThreshold && time.Since(unresponsiveSince) > timing.FailureTimeout {
+		// The transition fails if the container events already revealed the failure.
+		if mw.transition(component, Failed, Starting, Ready) == nil {
+			mw.handleFailure(ctx, component)
+		}
+	}
+}
+
+// handleFailure starts handling a component that was marked as Failed in the background.
+func (mw *Millwright) handleFailure(ctx context.Context, component *Component) {
+	mw.handlers.Add(1)
+	go func() {
+		defer mw.handlers.Done()
+		mw.HandleFailedComponent(ctx, component)
+	}()
+}
+
+// HandleFailedComponent relaunches a component that has failed and marks it as Starting,
 // so it can start being checked by Reconcile again.
+// Restarts are delayed with an exponential backoff, and a component that keeps failing is reported as crash looping.
+// Relaunches that fail are retried the same way until the component is started or stopped.
+// While monitoring is paused, restarts wait until it's resumed.
+// Once the relaunched component is ready, its dependents are restarted or signaled according to their policies.
 func (mw *Millwright) HandleFailedComponent(ctx context.Context, component *Component) {
-	_, err := mw.relaunchComponent(ctx, component)
+	// Save the crash while the container is still around, relaunching removes it.
+	crashPath, err := mw.saveCrash(ctx, component)
 	if err != nil {
+		log.Errorf("Can't save the crash of %s: %v", component.serviceName, err)
+	} else if crashPath != "" {
+		log.Infof("Crash of %s saved to %s.", component.serviceName, crashPath)
+	}
+
+	for {
+		restarts := component.recordRestart(time.Now())
+		if restarts >= crashLoopRestarts && mw.transition(component, CrashLoopBackOff, Failed) == nil {
+			log.Errorf(
+				"ACTION REQUIRED: %s is crash looping after %d restarts in %s: %s",
+				component.serviceName, restarts, crashLoopWindow, mw.crashReport(ctx, component),
+			)
+		}
+
+		delay := restartBackoff(restarts)
+		log.Infof("Restarting %s in %s.", component.serviceName, delay.Round(time.Millisecond))
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		if mw.isPaused() {
+			log.Infof("Restart of %s waits until monitoring is resumed.", component.serviceName)
+			if !mw.waitResumed(ctx) {
+				return
+			}
+		}
+
+		if err := mw.transition(component, Restarting, Failed, CrashLoopBackOff); err != nil {
+			// Stopped while waiting.
+			log.Infof("Not restarting %s: %v.", component.serviceName, err)
+			return
+		}
+
+		_, err := mw.relaunchComponent(ctx, component)
+		if err == nil {
+			// Start with a fresh probe state. If it still fails, Reconcile will flag it again.
+			// Once it's ready again, Reconcile will mark it as Ready.
+			component.resetProbes()
+			if err := mw.transition(component, Starting, Restarting); err != nil {
+				log.Infof("%s was relaunched but not started: %v.", component.serviceName, err)
+				return
+			}
+			mw.notifyDependents(ctx, component)
+			return
+		}
+
 		log.Errorf("ACTION REQUIRED: Failed component %s couldn't be relaunched: %v", component.serviceName, err)
+		if err := mw.transition(component, Failed, Restarting); err != nil {
+			return
+		}
+	}
+}
+
+// notifyDependents waits for a restarted component to be ready, and then applies the dependency policies of
+// the components that depend on it in topological order.
+// Dependents that are restarted are waited for in turn, and their own dependents are notified as well.
+func (mw *Millwright) notifyDependents(ctx context.Context, restarted *Component) {
+	notified := false
+	for _, component := range mw.currentComponents() {
+		notified = notified || component.dependencyPolicy(restarted) != DependencyNone
+	}
+	if !notified {
+		return
+	}
+
+	if err := mw.waitReady(ctx, restarted, restarted.timeToStart()); err != nil {
+		log.Infof("Not notifying the dependents of %s: %v.", restarted.serviceName, err)
+		return
+	}
+
+	restartedSet := map[*Component]bool{restarted: true}
+	for _, component := range topologicalOrder(mw.currentComponents()) {
+		policy := DependencyNone
+		var cause *Component
+		for _, dependency := range component.dependencies {
+			if !restartedSet[dependency] {
+				continue
+			}
+			// Restarting takes precedence over signaling when several dependencies were restarted.
+			switch component.dependencyPolicy(dependency) {
+			case DependencyRestart:
+				policy, cause = DependencyRestart, dependency
+			case DependencySignal:
+				if policy == DependencyNone {
+					policy, cause = DependencySignal, dependency
+				}
+			}
+		}
+
+		switch policy {
+		case DependencyRestart:
+			log.Infof("Restarting %s because %s was restarted.", component.serviceName, cause.serviceName)
+			if mw.restartComponent(ctx, component) {
+				restartedSet[component] = true
+			}
+		case DependencySignal:
+			log.Infof("Sending SIGHUP to %s because %s was restarted.", component.serviceName, cause.serviceName)
+			id, _ := component.container()
+			if err := mw.backend.SignalContainer(ctx, id, "SIGHUP"); err != nil {
+				log.Errorf("Can't signal %s: %v", component.serviceName, err)
+			}
+		}
+	}
+}
+
+// restartComponent relaunches a running component on purpose and waits for it to be ready.
+// It returns whether the component is ready again.
+// Components that have failed are left to HandleFailedComponent.
+func (mw *Millwright) restartComponent(ctx context.Context, component *Component) bool {
+	return mw.restartWith(ctx, component, mw.relaunchComponent)
+}
+
+// restartWith is restartComponent with the given way of relaunching the component.
+func (mw *Millwright) restartWith(
+	ctx context.Context, component *Component, relaunch func(context.Context, *Component) (string, error),
+) bool {
+	if err := mw.transition(component, Restarting, Starting, Ready); err != nil {
+		log.Infof("Not restarting %s: %v.", component.serviceName, err)
+		return false
+	}
+
+	if _, err := relaunch(ctx, component); err != nil {
+		log.Errorf("Can't restart %s: %v", component.serviceName, err)
+		if mw.transition(component, Failed, Restarting) == nil {
+			mw.handleFailure(ctx, component)
+		}
+		return false
+	}
+
+	component.resetProbes()
+	if err := mw.transition(component, Starting, Restarting); err != nil {
+		return false
+	}
+	if err := mw.waitReady(ctx, component, component.timeToStart()); err != nil {
+		log.Errorf("%s was restarted but isn't ready: %v", component.serviceName, err)
+		return false
+	}
+	return true
+}
+
+// restartBackoff returns the delay before the given restart of a component.
+// The delay doubles with each restart up to restartBackoffMax, and is jittered so that
+// components that failed together don't restart in lockstep.
+func restartBackoff(restart int) time.Duration {
+	delay := restartBackoffBase
+	for i := 1; i < restart && delay < restartBackoffMax; i++ {
+		delay *= 2
+	}
+	if delay > restartBackoffMax {
+		delay = restartBackoffMax
+	}
+	// Use a random delay between half and all of it.
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// crashReport describes why the container of a component stopped, including the tail of its logs.
+// It has to be called before the container is removed.
+func (mw *Millwright) crashReport(ctx context.Context, component *Component) string {
+	var report strings.Builder
+
+	containerID, _ := component.container()
+	exitReason := component.lastExitReason()
+
+	inspect, err := mw.backend.InspectContainer(ctx, containerID)
+	switch {
+	case exitReason != "":
+		fmt.Fprintf(&report, "container %s", exitReason)
+	case err != nil:
+		fmt.Fprintf(&report, "container can't be inspected: %v", err)
+	case inspect.Running:
+		report.WriteString("container is running but failing its health checks")
+	default:
+		fmt.Fprintf(&report, "container exited with code %d", inspect.ExitCode)
+	}
+
+	logs, err := mw.backend.Logs(ctx, containerID, crashReportLogLines)
+	if err != nil {
+		fmt.Fprintf(&report, ", logs can't be read: %v", err)
+	} else if logs != "" {
+		fmt.Fprintf(&report, ", last lines of its logs:\n%s", strings.TrimRight(logs, "\n"))
+	}
+
+	return report.String()
+}
+
+// checkReadiness runs the readiness probe of a started component if it is due, and marks it as Ready once it passes.
+// It returns whether the component is ready.
+func (mw *Millwright) checkReadiness(ctx context.Context, component *Component) bool {
+	switch component.currentStatus() {
+	case Ready:
+		return true
+	case Starting:
+	default:
+		return false
+	}
+
+	// Components that aren't checked are considered ready as soon as they're started.
+	if !component.ignore {
+		probe := component.readyProbe(ctx.Value(introspectionPortKey).(int), mw.timingOf(component).Interval)
+		if !component.readinessDue(probe) {
+			return false
+		}
+		err := mw.runProbe(ctx, component, probe)
+		if !component.recordReadiness(err == nil, probe) {
+			return false
+		}
 	}
 
-	// Set as running. If it still fails, Reconcile will flag it again.
-	component.status = Running
+	// The transition fails if the component failed while it was being checked.
+	if err := mw.transition(component, Ready, Starting); err != nil {
+		return false
+	}
+	log.Infof("%s is ready.", component.serviceName)
+	return true
 }
 
-// SendHeartbeat calls the introspection port for a component and returns weather the call succeeded.
-func (mw *Millwright) SendHeartbeat(component *Component) bool {
-	url := fmt.Sprintf("http://localhost:%s/debug/vars", component.inspectPort)
-	get, err := http.Get(url)
+// waitReady blocks until a started component is ready or its startup timeout expires.
+// A component that is being restarted is waited for until it's started again, and then until that start is ready or
+// times out. A component that isn't started again within the given timeout, e.g. because it's crash looping, isn't
+// waited for any longer.
+func (mw *Millwright) waitReady(ctx context.Context, component *Component, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for !mw.checkReadiness(ctx, component) {
+		switch status := component.currentStatus(); status {
+		case Starting:
+			if time.Now().After(component.startupDeadline()) {
+				return fmt.Errorf("%s is not ready after its startup timeout", component.serviceName)
+			}
+		case Stopped:
+			return fmt.Errorf("%s is stopped", component.serviceName)
+		default:
+			if time.Now().After(deadline) {
+				return fmt.Errorf("%s hasn't been started again within %s, it is %s", component.serviceName, timeout, status)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	return nil
+}
+
+// SendHeartbeat checks a component with its liveness probe and returns weather the check succeeded.
+// The check is abandoned once the timeout of the probe or the context expires.
+func (mw *Millwright) SendHeartbeat(ctx context.Context, component *Component, probe Probe) bool {
+	err := mw.runProbe(ctx, component, probe)
 	if err != nil {
 		log.Infof("Heartbeat failed for %s.", component.serviceName)
 		log.Error(err)
 		return false
 	}
-	get.Body.Close()
 	log.Infof("Successful heartbeat for %s.", component.serviceName)
 
 	return true
 }
 
-// LaunchComponentTree is called recursively to walk down the dependencies of a component until the entire
-// tree has been started.
-func (mw *Millwright) LaunchComponentTree(ctx context.Context, component *Component) error {
-	if component.status != Unstarted {
-		// LaunchComponentTree has already traversed this component.
-		return nil
+// launchWhenReady launches a component once its dependencies have been launched and are ready to serve.
+// A component that has already been launched by another internal instance is reused without waiting,
+// unless its container was created with settings that have changed since.
+// The launch itself takes one of the slots, which limit the number of components launched at the same time.
+func (mw *Millwright) launchWhenReady(
+	ctx context.Context, component *Component, launched map[*Component]chan struct{}, slots chan struct{},
+) error {
+	// The settings are computed once, hashing the build context of a component is expensive.
+	config, buildHash, err := mw.desiredConfig(ctx, component)
+	if err != nil {
+		return fmt.Errorf("can't launch %s: %v", component.serviceName, err)
 	}
 
 	// Check if component has been launched by another internal instance.
-	id, ok := mw.getComponent(ctx, component)
-	if ok {
+	// It's not known to be ready until its readiness probe passes.
+	decision, err := mw.decideLaunch(ctx, component, config)
+	if err != nil {
+		return fmt.Errorf("can't check the configuration of %s: %v", component.serviceName, err)
+	}
+	switch {
+	case decision.action == ActionNone:
 		log.Infof("Component %s has already been launched.", component.serviceName)
-		// Get its introspection port.
-		if !component.ignore {
-			// Find the component's introspection port and save it.
-			inspectPort, err := mw.getIntrospectionPort(ctx, id)
-			if err != nil {
-				// Likely the container exists but is not in the correct network.
-				// Let Reconcile deal with this.
-				return nil
-			}
-			component.inspectPort = inspectPort
+		if decision.hostPorts != nil {
+			component.setHostPorts(decision.hostPorts)
 		}
-		return nil
+		return mw.transition(component, Starting, Unstarted)
+	case len(decision.changed) > 0:
+		// Containers created with outdated settings are recreated below, in dependency order.
+		log.Infof(
+			"Configuration of %s has changed (%s), recreating it.",
+			component.serviceName, strings.Join(decision.changed, ", "),
+		)
+	case decision.action != ActionCreate:
+		log.Infof("Component %s is launched again, %s.", component.serviceName, decision.reason)
 	}
 
 	log.Infof("Checking dependencies for %s.", component.serviceName)
 
-	// Ensure dependencies are satisfied.
+	// Ensure dependencies are satisfied and ready to serve.
 	for _, dependency := range component.dependencies {
-		err := mw.LaunchComponentTree(ctx, dependency)
-		if err != nil {
-			return err
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-launched[dependency]:
+		}
+		log.Infof("Waiting for %s to be ready.", dependency.serviceName)
+		// Dependencies that aren't started again are only waited for as long as the component may take to start.
+		if err := mw.waitReady(ctx, dependency, component.timeToStart()); err != nil {
+			return fmt.Errorf("%s is blocked by dependency %s: %v", component.serviceName, dependency.serviceName, err)
 		}
 	}
 
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case slots <- struct{}{}:
+	}
+	defer func() { <-slots }()
+
 	log.Infof("Launching %s.", component.serviceName)
 
-	// Launch component.
-	_, err := mw.launchComponent(ctx, component)
-	if err != nil {
-		return err
+	// Launch component, replacing the unreachable container if there is one.
+	if _, err := mw.replaceContainer(ctx, component, config, buildHash); err != nil {
+		return fmt.Errorf("can't launch %s: %v", component.serviceName, err)
 	}
 
 	// Update component status.
-	component.status = Running
+	if err := mw.transition(component, Starting, Unstarted); err != nil {
+		return err
+	}
 
 	log.Infof("%s launched.", component.serviceName)
 
@@ -0,0 +1,764 @@
+package internal
+
+import (
+	"context"
+	"github.com/sirupsen/logrus/hooks/test"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testProject is the name of the project the tests run components in.
+const testProject = "test"
+
+// testContext returns a context with the values StartMillwright would set.
+func testContext(t *testing.T) context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	return projectContext(ctx, testProject)
+}
+
+// testBuildContext creates a build context with a Dockerfile.
+func testBuildContext(t *testing.T) string {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "Dockerfile"), []byte("FROM scratch\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+// testMillwright returns a millwright that manages a small component tree on a fake backend.
+func testMillwright(t *testing.T, backend *fakeBackend) *Millwright {
+	source := testComponent("source")
+	source.ignore = true
+	ingestion := testComponent("ingestion", source)
+	cpuHandler := testComponent("handler_cpu", ingestion)
+	loadHandler := testComponent("handler_load", ingestion)
+
+	mw := NewMillwright(backend, Options{})
+	mw.components = []*Component{cpuHandler, loadHandler, ingestion, source}
+	for _, component := range mw.components {
+		component.runConfig.BuildContextPath = testBuildContext(t)
+	}
+	return mw
+}
+
+// reconcile runs Reconcile in the background.
+// It returns a function that stops it and waits for it to return.
+func reconcile(ctx context.Context, mw *Millwright) func() {
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		mw.Reconcile(ctx)
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// fastTiming checks components often enough for tests to detect failures quickly.
+var fastTiming = Timing{Interval: 10 * time.Millisecond, FailureTimeout: 20 * time.Millisecond}
+
+// waitFor polls a condition until it holds or the test times out.
+func waitFor(t *testing.T, what string, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %s.", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// waitForReady waits until the components that are checked are ready, so that their failures aren't taken for a slow
+// startup.
+func waitForReady(t *testing.T, mw *Millwright) {
+	t.Helper()
+	waitFor(t, "the components to be ready", func() bool {
+		for _, component := range mw.currentComponents() {
+			if !component.ignore && component.currentStatus() != Ready {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+func TestStartLaunchesDependenciesFirst(t *testing.T) {
+	backend := newFakeBackend()
+	defer backend.close()
+	mw := testMillwright(t, backend)
+
+	if err := mw.Start(testContext(t)); err != nil {
+		t.Fatal(err)
+	}
+
+	created := map[string]int{}
+	for i, name := range backend.creates {
+		if _, ok := created[name]; ok {
+			t.Fatalf("%s was launched twice.", name)
+		}
+		created[name] = i
+	}
+
+	for _, component := range mw.components {
+		order, ok := created[ContainerName(testProject, component.serviceName)]
+		if !ok {
+			t.Fatalf("%s was not launched.", component.serviceName)
+		}
+		for _, dependency := range component.dependencies {
+			if created[ContainerName(testProject, dependency.serviceName)] > order {
+				t.Errorf("%s was launched before its dependency %s.", component.serviceName, dependency.serviceName)
+			}
+		}
+		if (component.status != Starting && component.status != Ready) || component.containerID == "" {
+			t.Errorf("%s is not marked as running.", component.serviceName)
+		}
+		if len(component.dependencies) > 0 && component.dependencies[0].status != Ready {
+			t.Errorf("%s was launched before %s was ready.", component.serviceName, component.dependencies[0].serviceName)
+		}
+		if !component.ignore && component.hostPorts[8089] == "" {
+			t.Errorf("%s has no introspection port.", component.serviceName)
+		}
+	}
+}
+
+func TestStartReusesRunningContainers(t *testing.T) {
+	backend := newFakeBackend()
+	defer backend.close()
+
+	if err := testMillwright(t, backend).Start(testContext(t)); err != nil {
+		t.Fatal(err)
+	}
+	backend.crash("handler_load")
+	creates := len(backend.creates)
+
+	// A new millwright should only launch the missing component.
+	mw := testMillwright(t, backend)
+	if err := mw.Start(testContext(t)); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(backend.creates) != creates+1 || backend.creates[creates] != "test-handler_load" {
+		t.Fatalf("Expected only handler_load to be relaunched, launches: %v.", backend.creates[creates:])
+	}
+	for _, component := range mw.components {
+		if component.containerID != backend.container(component.serviceName).ID {
+			t.Errorf("%s is not tracking the running container.", component.serviceName)
+		}
+	}
+}
+
+func TestStartLaunchesIndependentComponentsConcurrently(t *testing.T) {
+	backend := newFakeBackend()
+	defer backend.close()
+	backend.buildTime = 50 * time.Millisecond
+
+	if err := testMillwright(t, backend).Start(testContext(t)); err != nil {
+		t.Fatal(err)
+	}
+	// The handlers only share ingestion as a dependency.
+	if backend.maxBuilding != 2 {
+		t.Errorf("Expected the handlers to be built at the same time, concurrent builds: %d.", backend.maxBuilding)
+	}
+
+	backend = newFakeBackend()
+	defer backend.close()
+	backend.buildTime = 50 * time.Millisecond
+	mw := testMillwright(t, backend)
+	mw.parallelism = 1
+
+	if err := mw.Start(testContext(t)); err != nil {
+		t.Fatal(err)
+	}
+	if backend.maxBuilding != 1 {
+		t.Errorf("Parallelism wasn't respected, concurrent builds: %d.", backend.maxBuilding)
+	}
+}
+
+func TestStartAbortsPendingLaunches(t *testing.T) {
+	backend := newFakeBackend()
+	defer backend.close()
+	mw := testMillwright(t, backend)
+	for _, component := range mw.components {
+		if component.serviceName == "ingestion" {
+			component.runConfig = RunConfiguration{Image: "ingestion:missing", PullPolicy: PullNever}
+		}
+	}
+
+	err := mw.Start(testContext(t))
+	if err == nil || !strings.Contains(err.Error(), "can't launch ingestion") {
+		t.Fatalf("Expected start to fail launching ingestion, got: %v.", err)
+	}
+	if strings.Join(backend.creates, ",") != "test-source" {
+		t.Errorf("Expected only source to be launched, launches: %v.", backend.creates)
+	}
+	for _, component := range mw.components {
+		if component.serviceName != "source" && component.status != Unstarted {
+			t.Errorf("%s is %s after the launches were aborted.", component.serviceName, component.status)
+		}
+	}
+}
+
+func TestReconcileRelaunchesFailedComponents(t *testing.T) {
+	defer func(delay time.Duration) { restartBackoffBase = delay }(restartBackoffBase)
+	restartBackoffBase = 10 * time.Millisecond
+
+	backend := newFakeBackend()
+	defer backend.close()
+	mw := testMillwright(t, backend)
+	mw.timing = fastTiming
+
+	ctx := testContext(t)
+	if err := mw.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer reconcile(ctx, mw)()
+	waitForReady(t, mw)
+
+	crashed := backend.container("handler_cpu").ID
+	backend.crash("handler_cpu")
+	waitFor(t, "the crashed component to be relaunched", func() bool {
+		c := backend.container("handler_cpu")
+		return c != nil && c.Running && c.ID != crashed
+	})
+
+	// A container that lost its network can't be reached and is relaunched as well.
+	disconnected := backend.container("ingestion").ID
+	backend.disconnect("ingestion")
+	waitFor(t, "the disconnected component to be relaunched", func() bool {
+		c := backend.container("ingestion")
+		return c != nil && c.Running && c.ID != disconnected && len(c.Networks) == 1
+	})
+}
+
+func TestReconcileHandlesFailuresMissedWhilePaused(t *testing.T) {
+	defer func(delay time.Duration) { restartBackoffBase = delay }(restartBackoffBase)
+	restartBackoffBase = 10 * time.Millisecond
+
+	backend := newFakeBackend()
+	defer backend.close()
+	mw := testMillwright(t, backend)
+	mw.timing = fastTiming
+
+	ctx := testContext(t)
+	if err := mw.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer reconcile(ctx, mw)()
+	waitFor(t, "the events to be watched", backend.watched)
+
+	// Components that aren't sent heartbeats are only known to have failed from the events of their containers.
+	mw.setPaused(true)
+	crashed := backend.container("source").ID
+	backend.crash("source")
+	time.Sleep(100 * time.Millisecond)
+	if backend.container("source").ID != crashed {
+		t.Fatal("Component was relaunched while monitoring was paused.")
+	}
+	mw.setPaused(false)
+	waitFor(t, "the component that failed while monitoring was paused to be relaunched", func() bool {
+		c := backend.container("source")
+		return c != nil && c.Running && c.ID != crashed
+	})
+}
+
+func TestPausingHoldsPendingRestarts(t *testing.T) {
+	defer func(delay time.Duration) { restartBackoffBase = delay }(restartBackoffBase)
+	restartBackoffBase = 100 * time.Millisecond
+
+	backend := newFakeBackend()
+	defer backend.close()
+	mw := testMillwright(t, backend)
+	mw.timing = fastTiming
+
+	ctx := testContext(t)
+	if err := mw.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer reconcile(ctx, mw)()
+	waitForReady(t, mw)
+
+	// The restart is waiting for its backoff when monitoring is paused.
+	crashed := backend.container("handler_cpu").ID
+	backend.crash("handler_cpu")
+	waitFor(t, "the crash to be handled", func() bool { return mw.components[0].currentStatus() == Failed })
+	mw.setPaused(true)
+	time.Sleep(200 * time.Millisecond)
+	if backend.container("handler_cpu").ID != crashed {
+		t.Fatal("Component was relaunched while monitoring was paused.")
+	}
+	mw.setPaused(false)
+	waitFor(t, "the component to be relaunched once monitoring is resumed", func() bool {
+		c := backend.container("handler_cpu")
+		return c != nil && c.Running && c.ID != crashed
+	})
+}
+
+func TestLaunchPullPolicies(t *testing.T) {
+	backend := newFakeBackend()
+	defer backend.close()
+	backend.registry["postgres:14"] = true
+	mw := NewMillwright(backend, Options{})
+	ctx := testContext(t)
+
+	launch := func(policy PullPolicy) error {
+		component := &Component{
+			serviceName: "db",
+			runConfig:   RunConfiguration{Image: "postgres:14", PullPolicy: policy},
+			ignore:      true,
+		}
+		_, err := mw.relaunchComponent(ctx, component)
+		_ = backend.RemoveContainer(ctx, "db")
+		return err
+	}
+
+	if err := launch(PullNever); err == nil {
+		t.Fatal("Launch with pull policy never should fail when the image is missing.")
+	}
+	if err := launch(PullIfNotPresent); err != nil {
+		t.Fatal(err)
+	}
+	if err := launch(PullIfNotPresent); err != nil {
+		t.Fatal(err)
+	}
+	if len(backend.pulls) != 1 {
+		t.Fatalf("Image should be pulled only when missing, pulls: %v.", backend.pulls)
+	}
+	if err := launch(PullNever); err != nil {
+		t.Fatal(err)
+	}
+	if err := launch(PullAlways); err != nil {
+		t.Fatal(err)
+	}
+	if len(backend.pulls) != 2 || len(backend.builds) != 0 {
+		t.Fatalf("Unexpected pulls %v and builds %v.", backend.pulls, backend.builds)
+	}
+}
+
+func TestLaunchReusesUnchangedImages(t *testing.T) {
+	backend := newFakeBackend()
+	defer backend.close()
+	mw := NewMillwright(backend, Options{})
+	ctx := testContext(t)
+
+	component := testComponent("ingestion")
+	component.runConfig.BuildContextPath = testBuildContext(t)
+	component.ignore = true
+
+	for i := 0; i < 3; i++ {
+		if _, err := mw.relaunchComponent(ctx, component); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(backend.builds) != 1 {
+		t.Fatalf("Unchanged image was rebuilt, builds: %v.", backend.builds)
+	}
+
+	source := filepath.Join(component.runConfig.BuildContextPath, "main.go")
+	if err := os.WriteFile(source, []byte("package main\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mw.relaunchComponent(ctx, component); err != nil {
+		t.Fatal(err)
+	}
+	if len(backend.builds) != 2 {
+		t.Fatalf("Changed image was not rebuilt, builds: %v.", backend.builds)
+	}
+
+	mw.rebuild = true
+	if _, err := mw.relaunchComponent(ctx, component); err != nil {
+		t.Fatal(err)
+	}
+	if len(backend.builds) != 3 {
+		t.Fatalf("Image was not rebuilt with rebuild enabled, builds: %v.", backend.builds)
+	}
+}
+
+func TestReconcileUsesConfiguredProbe(t *testing.T) {
+	defer func(delay time.Duration) { restartBackoffBase = delay }(restartBackoffBase)
+	restartBackoffBase = 10 * time.Millisecond
+
+	backend := newFakeBackend()
+	defer backend.close()
+	mw := testMillwright(t, backend)
+	mw.timing = fastTiming
+	for _, component := range mw.components {
+		if component.serviceName == "handler_cpu" {
+			component.probe = &Probe{Type: ExecProbe, Command: []string{"healthy"}, FailureThreshold: 3}
+		}
+	}
+
+	ctx := testContext(t)
+	if err := mw.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer reconcile(ctx, mw)()
+	waitForReady(t, mw)
+
+	// A component that responds with an error is unhealthy.
+	unhealthy := backend.container("handler_load").ID
+	backend.respond("handler_load", http.StatusInternalServerError, 0)
+	waitFor(t, "the unhealthy component to be relaunched", func() bool {
+		c := backend.container("handler_load")
+		return c != nil && c.Running && c.ID != unhealthy
+	})
+
+	failing := backend.container("handler_cpu").ID
+	backend.respond("handler_cpu", http.StatusOK, 1)
+	waitFor(t, "the component failing its exec probe to be relaunched", func() bool {
+		c := backend.container("handler_cpu")
+		return c != nil && c.Running && c.ID != failing
+	})
+}
+
+func TestReconcileChecksComponentsConcurrently(t *testing.T) {
+	defer func(delay time.Duration) { restartBackoffBase = delay }(restartBackoffBase)
+	restartBackoffBase = 10 * time.Millisecond
+
+	backend := newFakeBackend()
+	defer backend.close()
+	mw := testMillwright(t, backend)
+	mw.timing = fastTiming
+	for _, component := range mw.components {
+		if component.serviceName == "handler_load" {
+			component.probe = &Probe{Timeout: 5 * time.Second}
+		}
+	}
+
+	ctx := testContext(t)
+	if err := mw.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer reconcile(ctx, mw)()
+
+	// A component that hangs is only given up on after its probe timeout.
+	backend.stall("handler_load", time.Minute)
+	time.Sleep(50 * time.Millisecond)
+
+	unhealthy := backend.container("handler_cpu").ID
+	started := time.Now()
+	backend.respond("handler_cpu", http.StatusInternalServerError, 0)
+	waitFor(t, "the unhealthy component to be relaunched", func() bool {
+		c := backend.container("handler_cpu")
+		return c != nil && c.Running && c.ID != unhealthy
+	})
+	if elapsed := time.Since(started); elapsed > time.Second {
+		t.Errorf("Failure detection was delayed by the hanging component, took %s.", elapsed)
+	}
+}
+
+func TestReconcileToleratesFailuresDuringGracePeriod(t *testing.T) {
+	defer func(delay time.Duration) { restartBackoffBase = delay }(restartBackoffBase)
+	restartBackoffBase = 10 * time.Millisecond
+
+	backend := newFakeBackend()
+	defer backend.close()
+	mw := testMillwright(t, backend)
+	mw.timing = fastTiming
+	for _, component := range mw.components {
+		if component.serviceName == "handler_load" {
+			component.timing.GracePeriod = 300 * time.Millisecond
+			component.startupTimeout = 100 * time.Millisecond
+		}
+	}
+
+	ctx := testContext(t)
+	if err := mw.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	started := time.Now()
+	booting := backend.container("handler_load").ID
+	backend.respond("handler_load", http.StatusServiceUnavailable, 0)
+	defer reconcile(ctx, mw)()
+
+	waitFor(t, "the failing component to be relaunched after its grace period", func() bool {
+		c := backend.container("handler_load")
+		return c != nil && c.Running && c.ID != booting
+	})
+	if elapsed := time.Since(started); elapsed < 300*time.Millisecond {
+		t.Errorf("Component was relaunched during its grace period, after %s.", elapsed)
+	}
+}
+
+func TestReconcileWaitsForStartupTimeout(t *testing.T) {
+	defer func(delay time.Duration) { restartBackoffBase = delay }(restartBackoffBase)
+	restartBackoffBase = 10 * time.Millisecond
+
+	backend := newFakeBackend()
+	defer backend.close()
+	mw := testMillwright(t, backend)
+	mw.timing = fastTiming
+	for _, component := range mw.components {
+		if component.serviceName == "handler_load" {
+			component.startupTimeout = 300 * time.Millisecond
+		}
+	}
+
+	ctx := testContext(t)
+	// The startup timeout runs from the launch of the component.
+	started := time.Now()
+	if err := mw.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	booting := backend.container("handler_load").ID
+	backend.respond("handler_load", http.StatusServiceUnavailable, 0)
+	defer reconcile(ctx, mw)()
+
+	waitFor(t, "the component that didn't start to be relaunched after its startup timeout", func() bool {
+		c := backend.container("handler_load")
+		return c != nil && c.Running && c.ID != booting
+	})
+	if elapsed := time.Since(started); elapsed < 300*time.Millisecond {
+		t.Errorf("Component was relaunched while it was starting, after %s.", elapsed)
+	}
+}
+
+func TestStartWaitsForReadiness(t *testing.T) {
+	backend := newFakeBackend()
+	defer backend.close()
+	backend.statuses["ingestion"] = http.StatusServiceUnavailable
+	mw := testMillwright(t, backend)
+	for _, component := range mw.components {
+		if component.serviceName == "ingestion" {
+			component.startupTimeout = 50 * time.Millisecond
+		}
+	}
+
+	err := mw.Start(testContext(t))
+	if err == nil || !strings.Contains(err.Error(), "is blocked by dependency ingestion") {
+		t.Fatalf("Expected start to be blocked by ingestion, got: %v.", err)
+	}
+	for _, name := range backend.creates {
+		if strings.HasPrefix(name, "test-handler") {
+			t.Errorf("%s was launched before its dependency was ready.", name)
+		}
+	}
+}
+
+func TestWaitReadyWaitsForRestarts(t *testing.T) {
+	backend := newFakeBackend()
+	defer backend.close()
+	mw := testMillwright(t, backend)
+	ctx := testContext(t)
+	if err := mw.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	ingestion := mw.components[2]
+	ingestion.startupTimeout = 50 * time.Millisecond
+	time.Sleep(60 * time.Millisecond)
+
+	// The deadline of the start before the failure has passed, the restart gets a deadline of its own.
+	if err := mw.transition(ingestion, Failed, Ready); err != nil {
+		t.Fatal(err)
+	}
+	waited := make(chan error)
+	go func() { waited <- mw.waitReady(ctx, ingestion, time.Second) }()
+	time.Sleep(30 * time.Millisecond)
+	if err := mw.transition(ingestion, Restarting, Failed); err != nil {
+		t.Fatal(err)
+	}
+	id, _ := ingestion.container()
+	ingestion.setContainer(id)
+	ingestion.resetProbes()
+	if err := mw.transition(ingestion, Starting, Restarting); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-waited; err != nil {
+		t.Errorf("Expected the restarted component to be waited for, got: %v.", err)
+	}
+}
+
+func TestCheckReadinessPromotesReadyComponents(t *testing.T) {
+
+	backend := newFakeBackend()
+	defer backend.close()
+	backend.statuses["handler_load"] = http.StatusServiceUnavailable
+	mw := testMillwright(t, backend)
+	mw.timing = fastTiming
+
+	ctx := testContext(t)
+	if err := mw.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing depends on handler_load, so it is started without being ready.
+	var component *Component
+	for _, c := range mw.components {
+		if c.serviceName == "handler_load" {
+			component = c
+		}
+	}
+	if mw.checkReadiness(ctx, component) || component.status != Starting {
+		t.Fatal("Component that isn't ready was marked as ready.")
+	}
+
+	backend.respond("handler_load", http.StatusOK, 0)
+	waitFor(t, "the component to become ready", func() bool {
+		return mw.checkReadiness(ctx, component)
+	})
+	if component.status != Ready {
+		t.Fatalf("Ready component is %s.", component.status)
+	}
+}
+
+func TestRestartBackoff(t *testing.T) {
+	cases := []struct {
+		restart int
+		max     time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{10, time.Minute},
+	}
+	for _, c := range cases {
+		for i := 0; i < 10; i++ {
+			if delay := restartBackoff(c.restart); delay < c.max/2 || delay > c.max {
+				t.Errorf("Backoff of restart %d is %s, expected between %s and %s.", c.restart, delay, c.max/2, c.max)
+			}
+		}
+	}
+}
+
+func TestHandleFailedComponentReportsCrashLoops(t *testing.T) {
+	defer func(delay time.Duration, restarts int) {
+		restartBackoffBase, crashLoopRestarts = delay, restarts
+	}(restartBackoffBase, crashLoopRestarts)
+	restartBackoffBase = 10 * time.Millisecond
+	crashLoopRestarts = 3
+
+	hook := test.NewGlobal()
+	defer hook.Reset()
+
+	backend := newFakeBackend()
+	defer backend.close()
+	mw := testMillwright(t, backend)
+	ctx := testContext(t)
+	if err := mw.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	component := mw.components[0]
+	started := time.Now()
+	for i := 0; i < crashLoopRestarts; i++ {
+		backend.crash(component.serviceName)
+		if err := mw.transition(component, Failed); err != nil {
+			t.Fatal(err)
+		}
+		mw.HandleFailedComponent(ctx, component)
+	}
+
+	// Restarts wait for at least half of 10ms, 20ms and 40ms.
+	if elapsed := time.Since(started); elapsed < 35*time.Millisecond {
+		t.Errorf("Restarts didn't back off, took %s.", elapsed)
+	}
+	if component.restartCount != 3 || component.status != Starting || !backend.container(component.serviceName).Running {
+		t.Errorf("Component wasn't restarted, restarts: %d, status: %s.", component.restartCount, component.status)
+	}
+
+	var reports []string
+	for _, entry := range hook.AllEntries() {
+		if strings.HasPrefix(entry.Message, "ACTION REQUIRED") {
+			reports = append(reports, entry.Message)
+		}
+	}
+	if len(reports) != 1 {
+		t.Fatalf("Expected a single crash loop report, got: %v.", reports)
+	}
+	for _, expected := range []string{"handler_cpu is crash looping after 3 restarts", "exited with code 2", "panic: handler_cpu crashed"} {
+		if !strings.Contains(reports[0], expected) {
+			t.Errorf("Report doesn't contain %q:\n%s", expected, reports[0])
+		}
+	}
+}
+
+func TestHandleFailedComponentNotifiesDependents(t *testing.T) {
+	defer func(delay time.Duration) { restartBackoffBase = delay }(restartBackoffBase)
+	restartBackoffBase = 10 * time.Millisecond
+
+	backend := newFakeBackend()
+	defer backend.close()
+	mw := testMillwright(t, backend)
+	var ingestion, cpuHandler, loadHandler *Component
+	for _, component := range mw.components {
+		switch component.serviceName {
+		case "ingestion":
+			ingestion = component
+		case "handler_cpu":
+			cpuHandler = component
+		case "handler_load":
+			loadHandler = component
+		}
+	}
+	cpuHandler.onRestart = map[*Component]DependencyPolicy{ingestion: DependencyRestart}
+	loadHandler.onRestart = map[*Component]DependencyPolicy{ingestion: DependencySignal}
+
+	ctx := testContext(t)
+	if err := mw.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	restarted := backend.container("handler_cpu").ID
+	signaled := backend.container("handler_load").ID
+	creates := len(backend.creates)
+
+	backend.crash("ingestion")
+	if err := mw.transition(ingestion, Failed); err != nil {
+		t.Fatal(err)
+	}
+	mw.HandleFailedComponent(ctx, ingestion)
+
+	if launched := backend.creates[creates:]; strings.Join(launched, ",") != "test-ingestion,test-handler_cpu" {
+		t.Errorf("Expected ingestion and then handler_cpu to be relaunched, launches: %v.", launched)
+	}
+	if backend.container("handler_cpu").ID == restarted || cpuHandler.status != Ready {
+		t.Errorf("Dependent with the restart policy wasn't restarted, it is %s.", cpuHandler.status)
+	}
+	if backend.container("handler_load").ID != signaled {
+		t.Error("Dependent with the signal policy was restarted.")
+	}
+	if strings.Join(backend.signals, ",") != "test-handler_load:SIGHUP" {
+		t.Errorf("Unexpected signals: %v.", backend.signals)
+	}
+}
+
+func TestReconcileHandlesContainerEvents(t *testing.T) {
+	defer func(delay time.Duration) { restartBackoffBase = delay }(restartBackoffBase)
+	restartBackoffBase = 10 * time.Millisecond
+
+	hook := test.NewGlobal()
+	defer hook.Reset()
+
+	backend := newFakeBackend()
+	defer backend.close()
+	mw := testMillwright(t, backend)
+
+	ctx := testContext(t)
+	if err := mw.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer reconcile(ctx, mw)()
+	waitFor(t, "the events to be watched", backend.watched)
+
+	// Ignored components don't get heartbeats, so only the event can reveal the crash.
+	crashed := backend.container("source").ID
+	backend.crash("source")
+	waitFor(t, "the crashed ignored component to be relaunched", func() bool {
+		c := backend.container("source")
+		return c != nil && c.Running && c.ID != crashed
+	})
+
+	var reported bool
+	for _, entry := range hook.AllEntries() {
+		reported = reported || entry.Message == "Container of source stopped: exited with code 2."
+	}
+	if !reported {
+		t.Error("The exit code of the crashed component wasn't recorded.")
+	}
+}
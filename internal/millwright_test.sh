@@ -2,17 +2,31 @@
 
 allComponents=('ingestion' 'dispatcher' 'handler_cpu_usage' 'handler_load' 'handler_kernel_upgrade')
 
-MILLWRIGHT=./internal/mw
+# A project of its own, so the test doesn't touch other stacks on the same Docker host.
+PROJECT=millwright-test
+MILLWRIGHT=(./internal/mw --project "$PROJECT")
 
 function check_health {
   for t in "${allComponents[@]}"; do
-    "$MILLWRIGHT" inspect "$t" >/dev/null || exit 1
+    "${MILLWRIGHT[@]}" inspect "$t" >/dev/null || exit 1
   done
 }
 
+# Crashes are simulated by removing the containers behind the back of millwright,
+# since the containers killed with mw kill are kept stopped by a running millwright.
+function crash {
+  docker rm -f "$PROJECT-$1" >/dev/null || exit 1
+}
+
 function kill_all {
   for t in "${allComponents[@]}"; do
-    "$MILLWRIGHT" kill "$t" >/dev/null || exit 1
+    "${MILLWRIGHT[@]}" kill "$t" >/dev/null || exit 1
+  done
+}
+
+function crash_all {
+  for t in "${allComponents[@]}"; do
+    crash "$t"
   done
 }
 
@@ -22,12 +36,12 @@ echo 'Building internal binary.'
 go build -o mw; cd ..
 
 echo 'Doing a preliminary cleanup.'
-"$MILLWRIGHT" destroy
+"${MILLWRIGHT[@]}" destroy
 
 echo 'Starting internal.'
 # --force just in case an internal is already running.
 # Would have liked to output only stderr but logrus logs INFO to stderr for some reason so silecing both.
-"$MILLWRIGHT" start --force &>/dev/null &
+"${MILLWRIGHT[@]}" start --force &>/dev/null &
 export MW_PID=$!
 
 echo 'Waiting up to 5 minutes for the internal to finish initialization.'
@@ -39,14 +53,14 @@ check_health
 
 echo 'Testing recovery of 1 failure while internal is running.'
 
-"$MILLWRIGHT" kill ingestion
+crash ingestion
 
 sleep 15
 check_health
 
 echo 'Testing recovery of multiple failures while internal is running.'
 
-kill_all
+crash_all
 
 sleep 20
 check_health
@@ -57,7 +71,7 @@ kill -INT "$MW_PID"
 kill_all
 
 # It should be able to start without --force now.
-"$MILLWRIGHT" start &>/dev/null &
+"${MILLWRIGHT[@]}" start &>/dev/null &
 export MW_PID=$!
 
 sleep 30
@@ -67,9 +81,9 @@ echo 'Testing recovery of network misconfiguration from new internal.'
 
 kill -INT "$MW_PID"
 
-docker network disconnect -f millwright-bridge dispatcher
+docker network disconnect -f "$PROJECT-bridge" "$PROJECT-dispatcher"
 
-"$MILLWRIGHT" start &>/dev/null &
+"${MILLWRIGHT[@]}" start &>/dev/null &
 export MW_PID=$!
 
 sleep 15
@@ -80,6 +94,6 @@ echo 'All tests passed.'
 echo 'Cleaning up.'
 
 kill -INT "$MW_PID"
-"$MILLWRIGHT" destroy
+"${MILLWRIGHT[@]}" destroy
 
 echo 'Exiting.'
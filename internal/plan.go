@@ -0,0 +1,221 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Actions that starting a project takes for a component or container.
+const (
+	ActionCreate   = "create"   // the component has no running container
+	ActionRecreate = "recreate" // the container was created with outdated settings or can't be reached
+	ActionReattach = "reattach" // the container isn't attached to the network of the project
+	ActionNone     = "no-op"    // the container is reused as it is
+	ActionOrphan   = "orphan"   // the container is labeled by millwright but isn't part of the configuration
+)
+
+// Ways the image of a component is prepared before its container is created.
+const (
+	ImageBuild = "build"
+	ImagePull  = "pull"
+	ImageReuse = "reuse"
+)
+
+// PlannedAction is what starting a project does for a component, or for a container that isn't part of it.
+type PlannedAction struct {
+	Component   string   `json:"component"`
+	Action      string   `json:"action"` // one of the Action constants
+	Reason      string   `json:"reason,omitempty"`
+	Changed     []string `json:"changed,omitempty"` // settings that have changed, when recreating because of drift
+	Image       string   `json:"image,omitempty"`   // one of the Image constants, when creating a container
+	ContainerID string   `json:"container_id,omitempty"`
+}
+
+// Plan is what starting a project does, in the order the components are started.
+type Plan struct {
+	Network       string          `json:"network"`
+	CreateNetwork bool            `json:"create_network"`
+	Actions       []PlannedAction `json:"actions"`
+}
+
+// Changes tells whether starting the project changes anything.
+// Orphans don't count, since they're left alone.
+func (p *Plan) Changes() bool {
+	if p.CreateNetwork {
+		return true
+	}
+	for _, action := range p.Actions {
+		if action.Action != ActionNone && action.Action != ActionOrphan {
+			return true
+		}
+	}
+	return false
+}
+
+// PlanStart returns what starting the given project would do, without changing anything.
+func PlanStart(ctx context.Context, project *Project, options Options) (*Plan, error) {
+	mw, ctx, err := newProjectMillwright(ctx, project, options)
+	if err != nil {
+		return nil, err
+	}
+	return mw.Plan(ctx)
+}
+
+// Plan inspects the network, containers and images of the components and returns what Start would do,
+// without changing anything.
+func (mw *Millwright) Plan(ctx context.Context) (*Plan, error) {
+	networkName := ctx.Value(networkNameKey).(string)
+	networks, err := mw.backend.ListNetworks(ctx, networkName)
+	if err != nil {
+		return nil, err
+	}
+	plan := &Plan{Network: networkName, CreateNetwork: len(networks) == 0, Actions: []PlannedAction{}}
+
+	for _, component := range topologicalOrder(mw.currentComponents()) {
+		action, err := mw.planComponent(ctx, component)
+		if err != nil {
+			return nil, fmt.Errorf("can't plan %s: %v", component.serviceName, err)
+		}
+		plan.Actions = append(plan.Actions, action)
+	}
+
+	containers, err := mw.backend.ListContainers(ctx, ListOptions{Label: projectFilter(ctx), All: true})
+	if err != nil {
+		return nil, err
+	}
+	_, orphans := mw.containersByComponent(containers)
+	for _, container := range orphans {
+		plan.Actions = append(plan.Actions, PlannedAction{
+			Component:   container.Name,
+			Action:      ActionOrphan,
+			Reason:      fmt.Sprintf("not in the configuration, %s, left as it is", containerState(container)),
+			ContainerID: container.ID,
+		})
+	}
+	return plan, nil
+}
+
+// planComponent decides what launchWhenReady does for a component.
+func (mw *Millwright) planComponent(ctx context.Context, component *Component) (PlannedAction, error) {
+	// The settings are computed once, hashing the build context of a component is expensive.
+	config, buildHash, err := mw.desiredConfig(ctx, component)
+	if err != nil {
+		return PlannedAction{Component: component.serviceName}, err
+	}
+	decision, err := mw.decideLaunch(ctx, component, config)
+	if err != nil {
+		return PlannedAction{Component: component.serviceName}, err
+	}
+	action := PlannedAction{
+		Component:   component.serviceName,
+		Action:      decision.action,
+		Reason:      decision.reason,
+		Changed:     decision.changed,
+		ContainerID: decision.id,
+	}
+	if decision.action == ActionNone {
+		return action, nil
+	}
+
+	image, err := mw.planImage(ctx, component, buildHash)
+	if err != nil {
+		return action, err
+	}
+	action.Image = image
+	return action, nil
+}
+
+// launchDecision is what launchWhenReady does for a component, as decided by decideLaunch.
+type launchDecision struct {
+	action    string         // one of the Action constants, except ActionOrphan
+	reason    string         // why the component is launched
+	changed   []string       // settings that have changed, when recreating because of drift
+	id        string         // current container of the component, if it has one
+	hostPorts map[int]string // host ports of the current container, when it's reused
+}
+
+// decideLaunch decides whether the current container of a component is reused by launchWhenReady, given the
+// fingerprint of the settings the container should have, or whether the component is launched again.
+func (mw *Millwright) decideLaunch(
+	ctx context.Context, component *Component, config containerConfig,
+) (launchDecision, error) {
+	// Check if component has been launched by another internal instance.
+	id, ok := mw.getComponent(ctx, component)
+	if !ok {
+		return launchDecision{action: ActionCreate, reason: "no running container"}, nil
+	}
+
+	decision := launchDecision{id: id}
+	changed, err := mw.configDrift(ctx, config, id)
+	if err != nil {
+		return decision, err
+	}
+	if len(changed) > 0 {
+		decision.action = ActionRecreate
+		decision.reason = fmt.Sprintf("configuration changed: %s", strings.Join(changed, ", "))
+		decision.changed = changed
+		return decision, nil
+	}
+	if component.ignore {
+		decision.action = ActionNone
+		return decision, nil
+	}
+
+	hostPorts, unreachable := mw.getHostPorts(ctx, id)
+	if unreachable == nil {
+		decision.action = ActionNone
+		decision.hostPorts = hostPorts
+		return decision, nil
+	}
+	// Likely the container exists but is not in the correct network.
+	// It can't become ready like that, so it is launched again.
+	networkName := ctx.Value(networkNameKey).(string)
+	container, err := mw.backend.InspectContainer(ctx, id)
+	if err == nil && !containsString(container.Networks, networkName) {
+		decision.action = ActionReattach
+		decision.reason = fmt.Sprintf("not attached to %s, relaunched on it", networkName)
+		return decision, nil
+	}
+	decision.action = ActionRecreate
+	decision.reason = fmt.Sprintf("can't be reached: %v", unreachable)
+	return decision, nil
+}
+
+// planImage decides what prepareImage does for a component whose build context has the given hash.
+func (mw *Millwright) planImage(ctx context.Context, component *Component, buildHash string) (string, error) {
+	runConfig := component.runConfig
+
+	if runConfig.Image == "" {
+		image, exists, err := mw.backend.InspectImage(ctx, scopedName(ctx, component))
+		if err != nil || mw.rebuild || !exists || image.Labels[buildHashLabel] != buildHash {
+			return ImageBuild, nil
+		}
+		return ImageReuse, nil
+	}
+
+	if runConfig.PullPolicy == PullAlways {
+		return ImagePull, nil
+	}
+	_, exists, err := mw.backend.InspectImage(ctx, runConfig.Image)
+	switch {
+	case err != nil:
+		return "", err
+	case exists:
+		return ImageReuse, nil
+	case runConfig.PullPolicy == PullNever:
+		return "", fmt.Errorf("image %s is not present and the pull policy is %s", runConfig.Image, PullNever)
+	default:
+		return ImagePull, nil
+	}
+}
+
+// containsString tells whether a list contains a string.
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,110 @@
+package internal
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPlanFreshProject(t *testing.T) {
+	backend := newFakeBackend()
+	defer backend.close()
+	mw := testMillwright(t, backend)
+
+	plan, err := mw.Plan(testContext(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !plan.CreateNetwork || !plan.Changes() {
+		t.Errorf("Expected the network to be created, plan: %+v.", plan)
+	}
+
+	var order []string
+	for _, action := range plan.Actions {
+		order = append(order, action.Component)
+		if action.Action != ActionCreate || action.Image != ImageBuild {
+			t.Errorf("Expected %s to be built and created, got %+v.", action.Component, action)
+		}
+	}
+	if strings.Join(order, ",") != "source,ingestion,handler_cpu,handler_load" {
+		t.Errorf("Actions aren't in the order of the launches: %v.", order)
+	}
+}
+
+func TestPlanExistingProject(t *testing.T) {
+	backend := newFakeBackend()
+	defer backend.close()
+	ctx := testContext(t)
+
+	if _, err := backend.CreateNetwork(ctx, "test-bridge", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := testMillwright(t, backend).Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	backend.crash("handler_load")
+	backend.disconnect("handler_cpu")
+	if _, err := backend.CreateContainer(ctx, ContainerSpec{
+		Name:   ContainerName(testProject, "handler_old"),
+		Image:  ContainerName(testProject, "ingestion"),
+		Labels: map[string]string{"used-by": "millwright", projectLabel: testProject, componentLabel: "handler_old"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	creates := len(backend.creates)
+
+	mw := testMillwright(t, backend)
+	for _, component := range mw.components {
+		if component.serviceName == "ingestion" {
+			component.runConfig.Env = append(component.runConfig.Env, "CHANGED=1")
+		}
+	}
+	plan, err := mw.Plan(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if plan.CreateNetwork || !plan.Changes() {
+		t.Errorf("Unexpected plan for the network: %+v.", plan)
+	}
+	actions := map[string]PlannedAction{}
+	for _, action := range plan.Actions {
+		actions[action.Component] = action
+	}
+	expected := map[string]string{
+		"source":           ActionNone,
+		"ingestion":        ActionRecreate,
+		"handler_cpu":      ActionReattach,
+		"handler_load":     ActionCreate,
+		"test-handler_old": ActionOrphan,
+	}
+	for name, action := range expected {
+		if actions[name].Action != action {
+			t.Errorf("Expected %s to be %s, got %+v.", name, action, actions[name])
+		}
+	}
+	if changed := actions["ingestion"].Changed; strings.Join(changed, ",") != "env" {
+		t.Errorf("Expected the env of ingestion to have changed, got %v.", changed)
+	}
+	if image := actions["handler_load"].Image; image != ImageReuse {
+		t.Errorf("Expected the image of handler_load to be reused, got %s.", image)
+	}
+
+	if len(backend.creates) != creates || len(backend.builds) != 4 {
+		t.Errorf("Planning changed the project, launches: %v, builds: %v.", backend.creates[creates:], backend.builds)
+	}
+
+	// Starting the project launches the components the plan says it does, and only those.
+	if err := mw.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	launched := map[string]bool{}
+	for _, name := range backend.creates[creates:] {
+		launched[name] = true
+	}
+	for _, action := range plan.Actions {
+		planned := action.Action != ActionNone && action.Action != ActionOrphan
+		if launched[ContainerName(testProject, action.Component)] != planned {
+			t.Errorf("Start didn't do what was planned for %s: %+v.", action.Component, action)
+		}
+	}
+}
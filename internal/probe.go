@@ -0,0 +1,249 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ProbeType is the way a Probe checks the health of a component.
+type ProbeType string
+
+// The supported probe types.
+const (
+	HTTPProbe   ProbeType = "http"   // GET request to a port of the container
+	TCPProbe    ProbeType = "tcp"    // connection to a port of the container
+	ExecProbe   ProbeType = "exec"   // command executed inside the container
+	DockerProbe ProbeType = "docker" // status of the HEALTHCHECK of the container image
+)
+
+// Probe specifies how the health of a component is checked.
+// Zero values are replaced by the defaults in withDefaults.
+type Probe struct {
+	Type ProbeType
+
+	Port int // container TCP port for HTTP and TCP probes, defaults to the introspection port
+
+	Path           string // path of HTTP probes, defaults to /debug/vars
+	ExpectedStatus []int  // accepted HTTP status codes, defaults to any 2xx or 3xx status
+	JSONField      string // dot-separated path of a field in the JSON response body to check
+	JSONEquals     string // expected value of JSONField
+
+	Command  []string // command of exec probes
+	ExitCode int      // expected exit code of exec probes
+
+	Timeout          time.Duration // time a single check may take
+	Period           time.Duration // time between checks
+	SuccessThreshold int           // consecutive successes needed to be considered healthy
+	FailureThreshold int           // consecutive failures needed to be considered failed
+}
+
+// Default probe settings.
+const (
+	defaultProbePath    = "/debug/vars"
+	defaultProbeTimeout = time.Second
+)
+
+// withDefaults returns a copy of the probe with zero values replaced by their defaults.
+// The period defaults to the given interval between heartbeats.
+func (p Probe) withDefaults(introspectionPort int, interval time.Duration) Probe {
+	if p.Type == "" {
+		p.Type = HTTPProbe
+	}
+	if p.Port == 0 && (p.Type == HTTPProbe || p.Type == TCPProbe) {
+		p.Port = introspectionPort
+	}
+	if p.Path == "" && p.Type == HTTPProbe {
+		p.Path = defaultProbePath
+	}
+	if p.Timeout == 0 {
+		p.Timeout = defaultProbeTimeout
+	}
+	if p.Period == 0 {
+		p.Period = interval
+	}
+	if p.SuccessThreshold == 0 {
+		p.SuccessThreshold = 1
+	}
+	if p.FailureThreshold == 0 {
+		p.FailureThreshold = 1
+	}
+	return p
+}
+
+// probeState tracks the consecutive results of a probe.
+type probeState struct {
+	lastRun   time.Time
+	successes int
+	failures  int
+	healthy   bool
+}
+
+// record adds the result of a check to the state.
+// The probe becomes healthy after SuccessThreshold consecutive successes,
+// and unhealthy after FailureThreshold consecutive failures.
+func (s *probeState) record(ok bool, probe Probe) {
+	s.lastRun = time.Now()
+	if ok {
+		s.successes++
+		s.failures = 0
+		if s.successes >= probe.SuccessThreshold {
+			s.healthy = true
+		}
+	} else {
+		s.failures++
+		s.successes = 0
+		if s.failures >= probe.FailureThreshold {
+			s.healthy = false
+		}
+	}
+}
+
+// due checks whether the probe should run again.
+func (s *probeState) due(probe Probe) bool {
+	return time.Since(s.lastRun) >= probe.Period
+}
+
+// runProbe checks a component once using the given probe.
+// It returns nil if the component is healthy, or an error describing why it isn't.
+func (mw *Millwright) runProbe(ctx context.Context, component *Component, probe Probe) error {
+	ctx, cancel := context.WithTimeout(ctx, probe.Timeout)
+	defer cancel()
+
+	containerID, hostPorts := component.container()
+	switch probe.Type {
+	case HTTPProbe:
+		return probeHTTP(ctx, hostPorts, probe)
+	case TCPProbe:
+		return probeTCP(ctx, hostPorts, probe)
+	case ExecProbe:
+		exitCode, err := mw.backend.Exec(ctx, containerID, probe.Command)
+		if err != nil {
+			return err
+		}
+		if exitCode != probe.ExitCode {
+			return fmt.Errorf("command exited with %d, expected %d", exitCode, probe.ExitCode)
+		}
+		return nil
+	case DockerProbe:
+		inspect, err := mw.backend.InspectContainer(ctx, containerID)
+		if err != nil {
+			return err
+		}
+		if inspect.Health != "healthy" {
+			if inspect.Health == "" {
+				return fmt.Errorf("container has no HEALTHCHECK")
+			}
+			return fmt.Errorf("container is %s", inspect.Health)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown probe type %s", probe.Type)
+	}
+}
+
+// probeAddress returns the host address the probed port of a component is bound to.
+func probeAddress(hostPorts map[int]string, probe Probe) (string, error) {
+	hostPort, ok := hostPorts[probe.Port]
+	if !ok {
+		return "", fmt.Errorf("port %d is not bound to the host", probe.Port)
+	}
+	return net.JoinHostPort("localhost", hostPort), nil
+}
+
+// probeHTTP sends a GET request to a component and checks the response status and body.
+func probeHTTP(ctx context.Context, hostPorts map[int]string, probe Probe) error {
+	address, err := probeAddress(hostPorts, probe)
+	if err != nil {
+		return err
+	}
+
+	url := fmt.Sprintf("http://%s%s", address, probe.Path)
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if !expectedStatus(probe, response.StatusCode) {
+		return fmt.Errorf("unexpected status %s", response.Status)
+	}
+
+	if probe.JSONField == "" {
+		return nil
+	}
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+	return checkJSONField(body, probe.JSONField, probe.JSONEquals)
+}
+
+// expectedStatus checks whether an HTTP status code is accepted by the probe.
+func expectedStatus(probe Probe, status int) bool {
+	if len(probe.ExpectedStatus) == 0 {
+		return status >= 200 && status < 400
+	}
+	for _, expected := range probe.ExpectedStatus {
+		if status == expected {
+			return true
+		}
+	}
+	return false
+}
+
+// checkJSONField checks that the field at the dot-separated path of a JSON document has the expected value.
+// Non-string values are compared using their JSON encoding, e.g. true or 42.
+func checkJSONField(body []byte, path string, expected string) error {
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return fmt.Errorf("response is not JSON: %v", err)
+	}
+
+	for _, key := range strings.Split(path, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("field %s not found in response", path)
+		}
+		value, ok = object[key]
+		if !ok {
+			return fmt.Errorf("field %s not found in response", path)
+		}
+	}
+
+	actual, ok := value.(string)
+	if !ok {
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		actual = string(encoded)
+	}
+	if actual != expected {
+		return fmt.Errorf("field %s is %s, expected %s", path, actual, expected)
+	}
+	return nil
+}
+
+// probeTCP opens a connection to a component.
+func probeTCP(ctx context.Context, hostPorts map[int]string, probe Probe) error {
+	address, err := probeAddress(hostPorts, probe)
+	if err != nil {
+		return err
+	}
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
@@ -0,0 +1,129 @@
+package internal
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// probedComponent returns a component with its probed port bound to the given server.
+func probedComponent(t *testing.T, handler http.HandlerFunc) (*Component, Probe) {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	_, hostPort, _ := net.SplitHostPort(server.Listener.Addr().String())
+
+	component := testComponent("probed")
+	component.hostPorts = map[int]string{8089: hostPort}
+	return component, Probe{}.withDefaults(8089, defaultTiming.Interval)
+}
+
+func TestHTTPProbe(t *testing.T) {
+	status := http.StatusOK
+	body := `{"health": {"status": "ok", "ready": true}}`
+	var path string
+	component, probe := probedComponent(t, func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(body))
+	})
+	mw := NewMillwright(newFakeBackend(), Options{})
+	ctx := testContext(t)
+
+	if err := mw.runProbe(ctx, component, probe); err != nil {
+		t.Fatalf("Probe failed but should have passed: %v", err)
+	}
+	if path != "/debug/vars" {
+		t.Errorf("Default probe requested %s.", path)
+	}
+
+	status = http.StatusInternalServerError
+	if err := mw.runProbe(ctx, component, probe); err == nil {
+		t.Error("Probe should fail on a 500 response.")
+	}
+
+	probe.ExpectedStatus = []int{http.StatusInternalServerError}
+	if err := mw.runProbe(ctx, component, probe); err != nil {
+		t.Errorf("Probe should accept expected status codes: %v", err)
+	}
+
+	status = http.StatusOK
+	probe.ExpectedStatus = nil
+	probe.JSONField, probe.JSONEquals = "health.status", "ok"
+	if err := mw.runProbe(ctx, component, probe); err != nil {
+		t.Errorf("Probe should pass the JSON assertion: %v", err)
+	}
+	probe.JSONField, probe.JSONEquals = "health.ready", "true"
+	if err := mw.runProbe(ctx, component, probe); err != nil {
+		t.Errorf("Probe should compare non-string fields by their JSON encoding: %v", err)
+	}
+	probe.JSONField, probe.JSONEquals = "health.missing", "ok"
+	if err := mw.runProbe(ctx, component, probe); err == nil {
+		t.Error("Probe should fail when the JSON field is missing.")
+	}
+	body = "not json"
+	probe.JSONField, probe.JSONEquals = "health.status", "ok"
+	if err := mw.runProbe(ctx, component, probe); err == nil {
+		t.Error("Probe should fail when the body isn't JSON.")
+	}
+}
+
+func TestHTTPProbeTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	component, probe := probedComponent(t, func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	})
+	probe.Timeout = 50 * time.Millisecond
+	mw := NewMillwright(newFakeBackend(), Options{})
+
+	start := time.Now()
+	if err := mw.runProbe(testContext(t), component, probe); err == nil {
+		t.Fatal("Probe of a hung component should fail.")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Probe didn't respect its timeout, took %v.", elapsed)
+	}
+}
+
+func TestTCPProbe(t *testing.T) {
+	component, probe := probedComponent(t, func(http.ResponseWriter, *http.Request) {})
+	probe.Type = TCPProbe
+	mw := NewMillwright(newFakeBackend(), Options{})
+
+	if err := mw.runProbe(testContext(t), component, probe); err != nil {
+		t.Fatalf("Probe failed but should have passed: %v", err)
+	}
+
+	probe.Port = 9000
+	if err := mw.runProbe(testContext(t), component, probe); err == nil {
+		t.Fatal("Probe of an unbound port should fail.")
+	}
+}
+
+func TestProbeStateThresholds(t *testing.T) {
+	probe := Probe{SuccessThreshold: 2, FailureThreshold: 3}
+	var state probeState
+
+	state.record(true, probe)
+	if state.healthy {
+		t.Fatal("Healthy before reaching the success threshold.")
+	}
+	state.record(true, probe)
+	if !state.healthy {
+		t.Fatal("Not healthy after reaching the success threshold.")
+	}
+	state.record(false, probe)
+	state.record(false, probe)
+	if !state.healthy {
+		t.Fatal("Unhealthy before reaching the failure threshold.")
+	}
+	state.record(false, probe)
+	if state.healthy {
+		t.Fatal("Healthy after reaching the failure threshold.")
+	}
+}
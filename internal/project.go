@@ -0,0 +1,164 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// defaultProjectName is used when no name can be derived from the directory of a project.
+const defaultProjectName = "millwright"
+
+// validProjectName matches the project names that can be used in the names of containers, images and networks.
+var validProjectName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// ProjectName derives the name of a project from the directory its configuration is in.
+// The name is lowercased and stripped of the characters that can't be used in the names of images.
+func ProjectName(dir string) string {
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '_', r == '-':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r - 'A' + 'a'
+		default:
+			return -1
+		}
+	}, filepath.Base(dir))
+
+	name = strings.TrimLeft(name, "_-")
+	if name == "" {
+		return defaultProjectName
+	}
+	return name
+}
+
+// ConfigProjectName returns the name of the project whose config file is at the given path, or of the built-in
+// configuration if the path is empty, without loading it. The loaders name projects the same way.
+func ConfigProjectName(path string) string {
+	if path == "" {
+		return ProjectName(".")
+	}
+	return ProjectName(filepath.Dir(path))
+}
+
+// CheckProjectName makes sure a project name can be used in the names of containers, images and networks.
+func CheckProjectName(name string) error {
+	if !validProjectName.MatchString(name) {
+		return fmt.Errorf(
+			"invalid project name %q, expected lowercase letters, digits, dashes and underscores, "+
+				"starting with a letter or digit", name,
+		)
+	}
+	return nil
+}
+
+// ContainerName returns the name of the container of a component of the project with the given name.
+// Images built for the component are tagged with the same name.
+func ContainerName(project string, component string) string {
+	return project + "-" + component
+}
+
+// ProjectFilter returns the label, in KEY=VALUE format, that all resources of the project with the given name have.
+func ProjectFilter(project string) string {
+	return projectLabel + "=" + project
+}
+
+// scopedName returns the name of the container and the tag of the built image of a component
+// in the project of the context.
+func scopedName(ctx context.Context, component *Component) string {
+	return ContainerName(ctx.Value(projectNameKey).(string), component.serviceName)
+}
+
+// projectFilter returns the label that the resources of the project of the context have.
+func projectFilter(ctx context.Context) string {
+	return ProjectFilter(ctx.Value(projectNameKey).(string))
+}
+
+// resourceLabels returns the labels that mark a resource as created by millwright for the project of the context.
+func resourceLabels(ctx context.Context) map[string]string {
+	return map[string]string{
+		"used-by":    ctx.Value(labelKey).(string),
+		projectLabel: ctx.Value(projectNameKey).(string),
+	}
+}
+
+// containersByComponent maps the given containers of the project to the components they belong to.
+// Containers that don't belong to any of the components are returned separately, sorted by name.
+func (mw *Millwright) containersByComponent(containers []*Container) (map[string]*Container, []*Container) {
+	known := make(map[string]bool, len(mw.currentComponents()))
+	for _, component := range mw.currentComponents() {
+		known[component.serviceName] = true
+	}
+
+	byComponent := make(map[string]*Container, len(containers))
+	var unknown []*Container
+	for _, container := range containers {
+		if name := container.Labels[componentLabel]; known[name] {
+			byComponent[name] = container
+		} else {
+			unknown = append(unknown, container)
+		}
+	}
+	sort.Slice(unknown, func(i, j int) bool { return unknown[i].Name < unknown[j].Name })
+	return byComponent, unknown
+}
+
+// FindComponent returns the ID of the container of the component with the given name in the given project.
+// Only running containers are considered, unless all is set.
+func FindComponent(ctx context.Context, project string, name string, all bool) (string, error) {
+	if err := CheckProjectName(project); err != nil {
+		return "", err
+	}
+	backend, err := NewDockerBackend()
+	if err != nil {
+		return "", err
+	}
+	return findComponent(ctx, backend, project, name, all)
+}
+
+// findComponent finds the container labeled as the component with the given name in the given project.
+// It fails if more than one container is, or if none is but there's a container with the name the component's
+// container would have that isn't managed by millwright.
+func findComponent(ctx context.Context, backend Backend, project string, name string, all bool) (string, error) {
+	list, err := backend.ListContainers(ctx, ListOptions{Label: ProjectFilter(project), Component: name, All: all})
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case len(list) == 1:
+		return list[0].ID, nil
+	case len(list) > 1:
+		names := make([]string, 0, len(list))
+		for _, container := range list {
+			names = append(names, container.Name)
+		}
+		sort.Strings(names)
+		return "", fmt.Errorf(
+			"component %s of project %s is ambiguous, it matches the containers %s",
+			name, project, strings.Join(names, ", "),
+		)
+	}
+
+	// Report containers that look like the component but weren't created by millwright for the project,
+	// rather than pretending the component has no container.
+	for _, containerName := range []string{ContainerName(project, name), name} {
+		unmanaged, err := backend.ListContainers(ctx, ListOptions{Name: containerName, All: all})
+		if err != nil {
+			return "", err
+		}
+		if len(unmanaged) > 0 {
+			return "", fmt.Errorf(
+				"container %s isn't managed by millwright as component %s of project %s",
+				containerName, name, project,
+			)
+		}
+	}
+	return "", fmt.Errorf("container for component %s of project %s not found", name, project)
+}
@@ -0,0 +1,125 @@
+package internal
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestProjectName(t *testing.T) {
+	for dir, expected := range map[string]string{
+		"/home/me/My Stack": "mystack",
+		"/srv/stack_2-dev/": "stack_2-dev",
+		"/tmp/.hidden":      "hidden",
+		"/":                 defaultProjectName,
+	} {
+		name := ProjectName(dir)
+		if name != expected {
+			t.Errorf("Expected the project in %s to be named %s, got %s.", dir, expected, name)
+		}
+		if err := CheckProjectName(name); err != nil {
+			t.Errorf("Derived name is invalid: %v", err)
+		}
+	}
+
+	// Commands that don't load the config name the project like the loaders do.
+	if name := ConfigProjectName("/srv/deploy/millwright.yaml"); name != "deploy" {
+		t.Errorf("Expected the project of a config file to be named after its directory, got %s.", name)
+	}
+
+	for _, name := range []string{"", "-stack", "Stack", "my stack", "../stack"} {
+		if CheckProjectName(name) == nil {
+			t.Errorf("Project name %q should be invalid.", name)
+		}
+	}
+}
+
+func TestProjectsShareBackend(t *testing.T) {
+	backend := newFakeBackend()
+	defer backend.close()
+
+	ctx := testContext(t)
+	if err := testMillwright(t, backend).Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	other := projectContext(ctx, "other")
+	mw := testMillwright(t, backend)
+	if err := mw.Start(other); err != nil {
+		t.Fatal(err)
+	}
+
+	// The second project launches its own containers instead of reusing the ones of the first.
+	if len(backend.creates) != 8 {
+		t.Fatalf("Expected both projects to launch all of their components, launches: %v.", backend.creates)
+	}
+	status, err := mw.Status(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(status.Unknown) != 0 {
+		t.Errorf("Containers of another project are listed: %+v.", status.Unknown)
+	}
+	for _, component := range status.Components {
+		if name := backend.container(component.ContainerID).Name; name != ContainerName("other", component.Name) {
+			t.Errorf("Status of %s is reported from container %s.", component.Name, name)
+		}
+	}
+
+	// Stopping one project leaves the other running.
+	if err := mw.Stop(other, nil); err != nil {
+		t.Fatal(err)
+	}
+	if c := backend.container(ContainerName(testProject, "ingestion")); !c.Running {
+		t.Error("Stopping a project stopped the containers of another one.")
+	}
+}
+
+func TestFindComponent(t *testing.T) {
+	backend := newFakeBackend()
+	defer backend.close()
+	ctx := testContext(t)
+
+	// The containers of a project whose name ends like the test project, e.g. other-test-ingestion.
+	if err := testMillwright(t, backend).Start(projectContext(ctx, "other-"+testProject)); err != nil {
+		t.Fatal(err)
+	}
+	mw := testMillwright(t, backend)
+	if _, ok := mw.getComponent(ctx, mw.components[2]); ok {
+		t.Fatal("Container of another project was taken for ingestion.")
+	}
+	if err := mw.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := findComponent(ctx, backend, testProject, "handler_cpu", false)
+	if err != nil || id != backend.container(ContainerName(testProject, "handler_cpu")).ID {
+		t.Errorf("Unexpected container %s of handler_cpu: %v.", id, err)
+	}
+	if _, err := findComponent(ctx, backend, testProject, "handler", false); err == nil ||
+		!strings.Contains(err.Error(), "not found") {
+		t.Errorf("Expected no container to match handler, got: %v.", err)
+	}
+
+	// Containers that weren't created by millwright.
+	for _, spec := range []ContainerSpec{
+		{Name: ContainerName(testProject, "extra"), Image: ContainerName(testProject, "ingestion")},
+		{Name: "ingestion2", Image: ContainerName(testProject, "ingestion"), Labels: map[string]string{
+			projectLabel: testProject, componentLabel: "ingestion",
+		}},
+	} {
+		id, err := backend.CreateContainer(ctx, spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := backend.StartContainer(ctx, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := findComponent(ctx, backend, testProject, "extra", false); err == nil ||
+		!strings.Contains(err.Error(), "isn't managed by millwright") {
+		t.Errorf("Expected the unmanaged container to be reported, got: %v.", err)
+	}
+	if _, err := findComponent(ctx, backend, testProject, "ingestion", false); err == nil ||
+		!strings.Contains(err.Error(), "ingestion2, test-ingestion") {
+		t.Errorf("Expected ingestion to be ambiguous, got: %v.", err)
+	}
+}
@@ -0,0 +1,234 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"reflect"
+	"strings"
+)
+
+// ReloadResult tells which components were affected by reloading the configuration.
+type ReloadResult struct {
+	Added   []string `json:"added,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+	// Changed components are recreated if the settings of their containers changed, their containers are kept
+	// otherwise.
+	Changed []string `json:"changed,omitempty"`
+	// Dependents of changed components are monitored again with their new dependencies, their containers are kept.
+	Dependents []string `json:"dependents,omitempty"`
+}
+
+// Empty tells whether reloading the configuration didn't change anything.
+func (r *ReloadResult) Empty() bool {
+	return len(r.Added)+len(r.Removed)+len(r.Changed)+len(r.Dependents) == 0
+}
+
+// String summarizes the components affected by reloading the configuration.
+func (r *ReloadResult) String() string {
+	var parts []string
+	for _, group := range []struct {
+		label string
+		names []string
+	}{
+		{"added", r.Added},
+		{"removed", r.Removed},
+		{"changed", r.Changed},
+		{"dependents", r.Dependents},
+	} {
+		if len(group.names) > 0 {
+			parts = append(parts, fmt.Sprintf("%s %s", group.label, strings.Join(group.names, ", ")))
+		}
+	}
+	if len(parts) == 0 {
+		return "no changes"
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Reload applies a new configuration of the project to the running components.
+// Components that are in both configurations and are defined the same way are left alone, so monitoring them
+// isn't interrupted. Components that were added are launched, and components that were removed are stopped and
+// their containers removed. Changed components and their dependents are taken over by their new definitions,
+// which reuse their containers unless the settings of the containers changed, and the dependents of the recreated
+// ones are notified according to their dependency policies. Components that were stopped stay stopped.
+// The timing of the project can't be reloaded, the timing of the millwright is kept.
+func (mw *Millwright) Reload(ctx context.Context, project *Project) (*ReloadResult, error) {
+	mw.changing.Lock()
+	defer mw.changing.Unlock()
+
+	if current := ctx.Value(projectNameKey).(string); project.Name != current {
+		return nil, fmt.Errorf("the configuration is for project %s, this millwright runs project %s", project.Name, current)
+	}
+	// Make sure the configuration is valid.
+	if err := checkConfiguration(project.Components, mw.timing); err != nil {
+		return nil, err
+	}
+	addIntrospectionPort(ctx, project.Components)
+
+	running := mw.currentComponents()
+	previous := make(map[string]*Component, len(running))
+	for _, component := range running {
+		previous[component.serviceName] = component
+	}
+
+	// Dependencies come first, so that the dependents of the replaced components are known to be affected.
+	result := &ReloadResult{}
+	replaced := map[string]bool{}
+	for _, component := range topologicalOrder(project.Components) {
+		name := component.serviceName
+		old, ok := previous[name]
+		switch {
+		case !ok:
+			result.Added = append(result.Added, name)
+		case mw.redefined(ctx, old, component):
+			result.Changed = append(result.Changed, name)
+			replaced[name] = true
+		case dependsOnAny(component, replaced):
+			result.Dependents = append(result.Dependents, name)
+			replaced[name] = true
+		}
+	}
+
+	// The components that are left alone are kept, the new definitions depend on them rather than on their copies.
+	components := make([]*Component, 0, len(project.Components))
+	byName := make(map[string]*Component, len(project.Components))
+	for _, component := range project.Components {
+		if old, ok := previous[component.serviceName]; ok && !replaced[component.serviceName] {
+			component = old
+		}
+		components = append(components, component)
+		byName[component.serviceName] = component
+	}
+	for _, component := range components {
+		if component == previous[component.serviceName] {
+			continue
+		}
+		for i, dependency := range component.dependencies {
+			component.dependencies[i] = byName[dependency.serviceName]
+		}
+		onRestart := make(map[*Component]DependencyPolicy, len(component.onRestart))
+		for dependency, policy := range component.onRestart {
+			onRestart[byName[dependency.serviceName]] = policy
+		}
+		component.onRestart = onRestart
+	}
+
+	var removed []*Component
+	order := topologicalOrder(running)
+	for i := len(order) - 1; i >= 0; i-- {
+		if _, ok := byName[order[i].serviceName]; !ok {
+			removed = append(removed, order[i])
+			result.Removed = append(result.Removed, order[i].serviceName)
+		}
+	}
+
+	if result.Empty() {
+		log.Info("The configuration hasn't changed.")
+		return result, nil
+	}
+	log.Infof("Reloading the configuration: %s.", result)
+
+	// The replaced components are no longer monitored, their containers are picked up by their new definitions.
+	containers := make(map[string]string, len(replaced))
+	for name := range replaced {
+		old := previous[name]
+		containers[name], _ = old.container()
+		if old.currentStatus() == Stopped {
+			_ = mw.transition(byName[name], Stopped)
+		}
+		_ = mw.transition(old, Stopped)
+	}
+	mw.setComponents(components)
+
+	// Dependents are removed before their dependencies.
+	for _, component := range removed {
+		if err := mw.stopComponent(ctx, component); err != nil {
+			return result, fmt.Errorf("can't stop %s: %v", component.serviceName, err)
+		}
+		if err := mw.removeContainers(ctx, component); err != nil {
+			return result, fmt.Errorf("can't remove %s: %v", component.serviceName, err)
+		}
+	}
+
+	// Only the new definitions are unstarted.
+	if err := mw.Start(ctx); err != nil {
+		return result, fmt.Errorf("can't start components: %v", err)
+	}
+
+	for _, name := range result.Changed {
+		// Components that were recreated count as restarted.
+		component := byName[name]
+		if id, _ := component.container(); id != containers[name] && component.currentStatus() != Stopped {
+			mw.notifyDependents(ctx, component)
+		}
+	}
+	return result, nil
+}
+
+// removeContainers removes the containers of a component, whether they're running or not.
+func (mw *Millwright) removeContainers(ctx context.Context, component *Component) error {
+	containers, err := mw.backend.ListContainers(ctx, ListOptions{
+		Label: projectFilter(ctx), Component: component.serviceName, All: true,
+	})
+	if err != nil {
+		return err
+	}
+	for _, container := range containers {
+		log.Infof("Removing container %s of %s.", container.Name, component.serviceName)
+		if err := mw.backend.RemoveContainer(ctx, container.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// redefined tells whether the definition of a running component differs from its definition in a new configuration,
+// including the build context of its image.
+func (mw *Millwright) redefined(ctx context.Context, running *Component, component *Component) bool {
+	if !reflect.DeepEqual(running.runConfig, component.runConfig) ||
+		running.ignore != component.ignore ||
+		!reflect.DeepEqual(running.probe, component.probe) ||
+		!reflect.DeepEqual(running.readinessProbe, component.readinessProbe) ||
+		running.startupTimeout != component.startupTimeout ||
+		running.timing != component.timing ||
+		!reflect.DeepEqual(dependencyPolicies(running), dependencyPolicies(component)) {
+		return true
+	}
+
+	id, _ := running.container()
+	if id == "" || component.runConfig.Image != "" {
+		return false
+	}
+	config, _, err := mw.desiredConfig(ctx, component)
+	if err != nil {
+		log.Infof("Can't check the configuration of %s: %v.", component.serviceName, err)
+		return false
+	}
+	// The container may be gone, which the heartbeats take care of.
+	changed, err := mw.configDrift(ctx, config, id)
+	if err != nil {
+		log.Infof("Can't check the configuration of %s: %v.", component.serviceName, err)
+		return false
+	}
+	return len(changed) > 0
+}
+
+// dependencyPolicies returns the dependency policies of a component by the names of its dependencies.
+func dependencyPolicies(component *Component) map[string]DependencyPolicy {
+	policies := make(map[string]DependencyPolicy, len(component.dependencies))
+	for _, dependency := range component.dependencies {
+		policies[dependency.serviceName] = component.dependencyPolicy(dependency)
+	}
+	return policies
+}
+
+// dependsOnAny tells whether a component depends directly on any of the components with the given names.
+func dependsOnAny(component *Component, names map[string]bool) bool {
+	for _, dependency := range component.dependencies {
+		if names[dependency.serviceName] {
+			return true
+		}
+	}
+	return false
+}
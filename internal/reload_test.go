@@ -0,0 +1,228 @@
+package internal
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// reloadedProject returns a copy of the configuration of a millwright, as loading the same file again would.
+func reloadedProject(mw *Millwright) *Project {
+	copies := map[*Component]*Component{}
+	var components []*Component
+	for _, component := range topologicalOrder(mw.components) {
+		c := &Component{
+			serviceName:    component.serviceName,
+			runConfig:      component.runConfig,
+			ignore:         component.ignore,
+			probe:          component.probe,
+			readinessProbe: component.readinessProbe,
+			startupTimeout: component.startupTimeout,
+			timing:         component.timing,
+		}
+		// The introspection port is added again by Reload.
+		c.runConfig.Env = nil
+		for _, dependency := range component.dependencies {
+			c.dependencies = append(c.dependencies, copies[dependency])
+		}
+		copies[component] = c
+		components = append(components, c)
+	}
+	return &Project{Name: testProject, Components: components}
+}
+
+// byServiceName returns the component with the given name.
+func byServiceName(components []*Component, name string) *Component {
+	for _, component := range components {
+		if component.serviceName == name {
+			return component
+		}
+	}
+	return nil
+}
+
+// startForReload starts the components of the test millwright the way StartMillwright does.
+func startForReload(t *testing.T, backend *fakeBackend) *Millwright {
+	mw := testMillwright(t, backend)
+	ctx := testContext(t)
+	addIntrospectionPort(ctx, mw.components)
+	if err := mw.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	return mw
+}
+
+func TestReloadUnchangedConfiguration(t *testing.T) {
+	backend := newFakeBackend()
+	defer backend.close()
+	mw := startForReload(t, backend)
+	creates := len(backend.creates)
+
+	result, err := mw.Reload(testContext(t), reloadedProject(mw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Empty() || len(backend.creates) != creates {
+		t.Errorf("Expected nothing to change, got: %s, launches: %v.", result, backend.creates[creates:])
+	}
+}
+
+func TestReloadAppliesChanges(t *testing.T) {
+	backend := newFakeBackend()
+	defer backend.close()
+	mw := startForReload(t, backend)
+	ctx := testContext(t)
+	ingestion := byServiceName(mw.components, "ingestion")
+	ingestionID, _ := ingestion.container()
+	loadHandlerID := backend.container("handler_load").ID
+
+	project := reloadedProject(mw)
+	newIngestion := byServiceName(project.Components, "ingestion")
+	byServiceName(project.Components, "handler_load").runConfig.Env = []string{"LEVEL=debug"}
+	kernelHandler := testComponent("handler_kernel", newIngestion)
+	kernelHandler.runConfig.BuildContextPath = testBuildContext(t)
+	var components []*Component
+	for _, component := range project.Components {
+		if component.serviceName != "handler_cpu" {
+			components = append(components, component)
+		}
+	}
+	project.Components = append(components, kernelHandler)
+
+	result, err := mw.Reload(ctx, project)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &ReloadResult{Added: []string{"handler_kernel"}, Removed: []string{"handler_cpu"}, Changed: []string{"handler_load"}}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Expected %s, got %s.", expected, result)
+	}
+
+	if byServiceName(mw.currentComponents(), "ingestion") != ingestion {
+		t.Error("Unchanged ingestion was replaced.")
+	}
+	if id, _ := ingestion.container(); id != ingestionID || ingestion.currentStatus() != Ready {
+		t.Errorf("Unchanged ingestion was disturbed, it is %s.", ingestion.currentStatus())
+	}
+	if backend.container("handler_cpu") != nil || byServiceName(mw.currentComponents(), "handler_cpu") != nil {
+		t.Error("Removed handler_cpu is still around.")
+	}
+	if backend.container("handler_load").ID == loadHandlerID {
+		t.Error("Changed handler_load wasn't recreated.")
+	}
+	if kernelHandler.dependencies[0] != ingestion {
+		t.Error("Added handler_kernel doesn't depend on the running ingestion.")
+	}
+	if status := kernelHandler.currentStatus(); status != Starting && status != Ready {
+		t.Errorf("Added handler_kernel wasn't launched, it is %s.", status)
+	}
+}
+
+func TestReloadedComponentsAreMonitored(t *testing.T) {
+	backend := newFakeBackend()
+	defer backend.close()
+	mw := startForReload(t, backend)
+	mw.timing = fastTiming
+	ctx := testContext(t)
+	defer reconcile(ctx, mw)()
+
+	// More components than the heartbeats were queued for when monitoring started.
+	project := reloadedProject(mw)
+	ingestion := byServiceName(project.Components, "ingestion")
+	for _, name := range []string{"handler_kernel", "handler_disk"} {
+		component := testComponent(name, ingestion)
+		component.runConfig.BuildContextPath = testBuildContext(t)
+		project.Components = append(project.Components, component)
+	}
+	if _, err := mw.Reload(ctx, project); err != nil {
+		t.Fatal(err)
+	}
+	waitForReady(t, mw)
+}
+
+func TestReloadGivesUpOnCrashLoopingDependencies(t *testing.T) {
+	backend := newFakeBackend()
+	defer backend.close()
+	mw := startForReload(t, backend)
+	ctx := testContext(t)
+
+	// ingestion failed and isn't being restarted.
+	ingestion := byServiceName(mw.components, "ingestion")
+	if err := mw.transition(ingestion, Failed, Ready); err != nil {
+		t.Fatal(err)
+	}
+	project := reloadedProject(mw)
+	kernelHandler := testComponent("handler_kernel", byServiceName(project.Components, "ingestion"))
+	kernelHandler.runConfig.BuildContextPath = testBuildContext(t)
+	kernelHandler.startupTimeout = 50 * time.Millisecond
+	project.Components = append(project.Components, kernelHandler)
+
+	reloaded := make(chan error, 1)
+	go func() {
+		_, err := mw.Reload(ctx, project)
+		reloaded <- err
+	}()
+	select {
+	case err := <-reloaded:
+		if err == nil || !strings.Contains(err.Error(), "dependency ingestion") {
+			t.Errorf("Expected the failed dependency to be reported, got: %v.", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Reload is still waiting for the failed dependency.")
+	}
+}
+
+func TestReloadKeepsContainersOfDependents(t *testing.T) {
+	backend := newFakeBackend()
+	defer backend.close()
+	mw := startForReload(t, backend)
+	ctx := testContext(t)
+	ids := map[string]string{}
+	for _, component := range mw.components {
+		ids[component.serviceName], _ = component.container()
+	}
+	oldIngestion := byServiceName(mw.components, "ingestion")
+	defer reconcile(ctx, mw)()
+
+	// Monitoring settings don't need the containers to be recreated.
+	project := reloadedProject(mw)
+	byServiceName(project.Components, "ingestion").timing = fastTiming
+	result, err := mw.Reload(ctx, project)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &ReloadResult{Changed: []string{"ingestion"}, Dependents: []string{"handler_cpu", "handler_load"}}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Expected %s, got %s.", expected, result)
+	}
+
+	ingestion := byServiceName(mw.currentComponents(), "ingestion")
+	if oldIngestion.currentStatus() != Stopped || ingestion == oldIngestion || ingestion.timing != fastTiming {
+		t.Error("Changed ingestion wasn't taken over by its new definition.")
+	}
+	for _, component := range mw.currentComponents() {
+		if id, _ := component.container(); id != ids[component.serviceName] {
+			t.Errorf("Container of %s was replaced.", component.serviceName)
+		}
+		if component.serviceName == "handler_cpu" && component.dependencies[0] != ingestion {
+			t.Error("handler_cpu doesn't depend on the new definition of ingestion.")
+		}
+	}
+}
+
+func TestReloadRejectsInvalidConfiguration(t *testing.T) {
+	backend := newFakeBackend()
+	defer backend.close()
+	mw := startForReload(t, backend)
+	running := mw.components
+
+	project := reloadedProject(mw)
+	project.Components = append(project.Components, testComponent("ingestion"))
+	if _, err := mw.Reload(testContext(t), project); err == nil {
+		t.Error("Expected a configuration with duplicate components to be rejected.")
+	}
+	if !reflect.DeepEqual(mw.currentComponents(), running) {
+		t.Error("The components were changed by an invalid configuration.")
+	}
+}
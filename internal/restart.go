@@ -0,0 +1,80 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+)
+
+// RestartComponents relaunches the containers of the components of the given project with the given names,
+// or of all of its components if no names are given.
+// It's used when no millwright is running for the project, so the dependents of the components aren't notified.
+func RestartComponents(ctx context.Context, project *Project, names []string) error {
+	mw, ctx, err := newProjectMillwright(ctx, project, Options{})
+	if err != nil {
+		return err
+	}
+	return mw.Restart(ctx, names)
+}
+
+// Restart relaunches the components with the given names, or all components if no names are given, on purpose.
+// Components are restarted in dependency order, each once the previous one is ready again, and the dependents of
+// the components that were running are notified according to their dependency policies.
+// Components that were stopped are started again. Restarts aren't counted as crashes.
+func (mw *Millwright) Restart(ctx context.Context, names []string) error {
+	// Reloads may be swapping the components.
+	mw.changing.Lock()
+	defer mw.changing.Unlock()
+
+	selected, err := mw.selectComponents(names)
+	if err != nil {
+		return err
+	}
+
+	// Dependents that are restarted because of their dependency policies aren't restarted twice.
+	containers := make(map[*Component]string, len(selected))
+	for component := range selected {
+		containers[component], _ = component.container()
+	}
+
+	for _, component := range topologicalOrder(mw.currentComponents()) {
+		if id, _ := component.container(); !selected[component] || id != containers[component] {
+			continue
+		}
+
+		switch current := component.currentStatus(); current {
+		case Starting, Ready:
+			if !mw.restartComponent(ctx, component) {
+				return fmt.Errorf("%s couldn't be restarted, see the logs of its millwright", component.serviceName)
+			}
+			mw.notifyDependents(ctx, component)
+		case Unstarted, Stopped:
+			if err := mw.startAgain(ctx, component); err != nil {
+				return fmt.Errorf("can't restart %s: %v", component.serviceName, err)
+			}
+			if current == Stopped {
+				mw.notifyDependents(ctx, component)
+			}
+		default:
+			return fmt.Errorf("%s is %s and is already being restarted", component.serviceName, current)
+		}
+	}
+	return nil
+}
+
+// startAgain relaunches a component that isn't monitored, because it was stopped or because this millwright
+// doesn't monitor the project, and waits for it to be ready.
+func (mw *Millwright) startAgain(ctx context.Context, component *Component) error {
+	// Pick up the container and restart count of the component, if it's running.
+	mw.getComponent(ctx, component)
+
+	log.Infof("Restarting %s.", component.serviceName)
+	if _, err := mw.relaunchComponent(ctx, component); err != nil {
+		return err
+	}
+	component.resetProbes()
+	if err := mw.transition(component, Starting, Unstarted, Stopped); err != nil {
+		return err
+	}
+	return mw.waitReady(ctx, component, component.timeToStart())
+}
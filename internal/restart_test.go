@@ -0,0 +1,79 @@
+package internal
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRestartNotifiesDependents(t *testing.T) {
+	backend := newFakeBackend()
+	defer backend.close()
+	mw := testMillwright(t, backend)
+	cpuHandler, ingestion := mw.components[0], mw.components[2]
+	cpuHandler.onRestart = map[*Component]DependencyPolicy{ingestion: DependencyRestart}
+
+	ctx := testContext(t)
+	if err := mw.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	creates := len(backend.creates)
+
+	if err := mw.Restart(ctx, []string{"handler_cpu", "ingestion"}); err != nil {
+		t.Fatal(err)
+	}
+	if launched := backend.creates[creates:]; strings.Join(launched, ",") != "test-ingestion,test-handler_cpu" {
+		t.Errorf("Expected ingestion and then handler_cpu to be restarted once, launches: %v.", launched)
+	}
+	if cpuHandler.currentStatus() != Ready || ingestion.currentStatus() != Ready {
+		t.Errorf("Restarted components are %s and %s.", cpuHandler.currentStatus(), ingestion.currentStatus())
+	}
+	if restarts := ingestion.totalRestarts(); restarts != 0 {
+		t.Errorf("Restart on purpose was counted as a crash, restarts: %d.", restarts)
+	}
+}
+
+func TestRestartStartsStoppedComponents(t *testing.T) {
+	backend := newFakeBackend()
+	defer backend.close()
+	mw := testMillwright(t, backend)
+	ctx := testContext(t)
+	if err := mw.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := mw.Stop(ctx, []string{"handler_load"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mw.Restart(ctx, []string{"handler_load"}); err != nil {
+		t.Fatal(err)
+	}
+	if status := mw.components[1].currentStatus(); status != Ready || !backend.container("handler_load").Running {
+		t.Errorf("Stopped component wasn't started again, it is %s.", status)
+	}
+}
+
+func TestRestartUnmonitoredComponents(t *testing.T) {
+	backend := newFakeBackend()
+	defer backend.close()
+	ctx := testContext(t)
+	if err := testMillwright(t, backend).Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	previous := backend.container("ingestion").ID
+
+	// A millwright that doesn't monitor the components, like the one of `mw restart` without a running millwright.
+	mw := testMillwright(t, backend)
+	if err := mw.Restart(ctx, []string{"ingestion"}); err != nil {
+		t.Fatal(err)
+	}
+	if id := backend.container("ingestion").ID; id == previous || backend.container(previous) != nil {
+		t.Error("The container of ingestion wasn't replaced.")
+	}
+
+	if err := mw.transition(mw.components[2], Failed); err != nil {
+		t.Fatal(err)
+	}
+	if err := mw.Restart(ctx, []string{"ingestion"}); err == nil || !strings.Contains(err.Error(), "already being restarted") {
+		t.Errorf("Expected failed components to be left to their handler, got: %v.", err)
+	}
+}
@@ -3,7 +3,10 @@ package internal
 import (
 	"context"
 	"fmt"
+	"github.com/docker/distribution/reference"
 	log "github.com/sirupsen/logrus"
+	"strconv"
+	"strings"
 )
 
 type key int
@@ -13,41 +16,71 @@ const (
 	introspectionPortKey key = iota
 	networkNameKey
 	labelKey
+	projectNameKey
 )
 
-// StartMillwright configures, creates, and launches a new internal instance.
-func StartMillwright(ctx context.Context) {
-	// Create new internal instance
-	mw := NewMillwright()
-
+// projectContext adds the settings that are shared by all the components of the project with the given name
+// to the context.
+func projectContext(ctx context.Context, project string) context.Context {
 	// I'm not a fan of adding values to context but these would transit a lot of function signatures,
 	// so I think it's appropriate here.
-	introspectionPort := 8089
-	ctx = context.WithValue(ctx, introspectionPortKey, introspectionPort)
-	networkName := "millwright-bridge"
-	ctx = context.WithValue(ctx, networkNameKey, networkName)
+	ctx = context.WithValue(ctx, introspectionPortKey, 8089)
+	ctx = context.WithValue(ctx, networkNameKey, project+"-bridge")
 	// This will be used to clean up resources.
 	ctx = context.WithValue(ctx, labelKey, "millwright")
+	// Resources are named and labeled after the project so that several projects can share a Docker host.
+	ctx = context.WithValue(ctx, projectNameKey, project)
+	return ctx
+}
 
-	// Get configuration from config.go and make sure it is valid.
-	components := configureComponents()
-	err := checkConfiguration(components)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	// Add the INTROSPECTION_PORT env var to the components.
+// addIntrospectionPort adds the INTROSPECTION_PORT env var to the components that are checked.
+func addIntrospectionPort(ctx context.Context, components []*Component) {
 	for _, component := range components {
 		if component.ignore {
 			continue
 		}
 		component.runConfig.Env = append(component.runConfig.Env,
-			fmt.Sprintf("INTROSPECTION_PORT=%d", introspectionPort),
+			fmt.Sprintf("INTROSPECTION_PORT=%d", ctx.Value(introspectionPortKey).(int)),
 		)
 	}
+}
+
+// newProjectMillwright creates a millwright backed by the local Docker engine that manages the components of the
+// given project, and returns it with a context that carries the values of the project.
+// The timing in the options takes precedence over the timing of the project.
+func newProjectMillwright(ctx context.Context, project *Project, options Options) (*Millwright, context.Context, error) {
+	backend, err := NewDockerBackend()
+	if err != nil {
+		return nil, nil, err
+	}
+	options.Timing = options.Timing.withDefaults(project.Timing)
+	mw := NewMillwright(backend, options)
+
+	// Make sure the configuration is valid.
+	if err := checkConfiguration(project.Components, mw.timing); err != nil {
+		return nil, nil, err
+	}
+	mw.components = project.Components
+
+	ctx = projectContext(ctx, project.Name)
+	addIntrospectionPort(ctx, project.Components)
+	return mw, ctx, nil
+}
 
-	// Save component configuration to internal.
-	mw.components = components
+// StartMillwright configures, creates, and launches a new internal instance
+// that manages the components of the given project.
+// The timing in the options takes precedence over the timing of the project.
+// The configuration is loaded again with load when it's reloaded through the control API.
+func StartMillwright(ctx context.Context, project *Project, options Options, load func() (*Project, error)) {
+	// The timing given in the options is kept to tell whether reloads change the timing of the project.
+	timing := options.Timing
+
+	// Create new internal instance backed by the local Docker engine
+	mw, ctx, err := newProjectMillwright(ctx, project, options)
+	if err != nil {
+		log.Fatal(err)
+	}
+	networkName := ctx.Value(networkNameKey).(string)
 
 	// Return if context has been cancelled.
 	select {
@@ -70,24 +103,267 @@ func StartMillwright(ctx context.Context) {
 		log.Fatalf("can't start components: %v", err)
 	}
 
+	reload := func(ctx context.Context) (*ReloadResult, error) {
+		project, err := load()
+		if err != nil {
+			return nil, err
+		}
+		if timing.withDefaults(project.Timing).withDefaults(defaultTiming) != mw.timing {
+			log.Warn("The timing of the project has changed, it's applied once millwright is started again.")
+		}
+		return mw.Reload(ctx, project)
+	}
+
+	// Serve the control API to the other commands while monitoring, so that they act through this millwright.
+	served := make(chan struct{})
+	go func() {
+		defer close(served)
+		if err := mw.ServeControl(ctx, ControlSocket(project.Name), reload); err != nil {
+			log.Errorf("Can't serve the control API: %v", err)
+		}
+	}()
+	defer func() { <-served }()
+
 	// Start reconciliation loop
 	mw.Reconcile(ctx)
 }
 
+// configurationError aggregates all the problems found in a component configuration.
+type configurationError struct {
+	problems []string
+}
+
+func (e *configurationError) Error() string {
+	return fmt.Sprintf("invalid configuration:\n  - %s", strings.Join(e.problems, "\n  - "))
+}
+
+// addf records a problem with the configuration.
+func (e *configurationError) addf(format string, args ...interface{}) {
+	e.problems = append(e.problems, fmt.Sprintf(format, args...))
+}
+
 // checkConfiguration is used to ensure a component configuration is valid.
-// Currently, it only (inefficiently) checks of direct cyclic dependencies.
-func checkConfiguration(components []*Component) error {
+// It checks for duplicate and unknown components, incomplete run configurations, cyclic dependencies,
+// and timings that don't allow a component to be checked before it's considered failed.
+// All problems found are returned together in a single error.
+func checkConfiguration(components []*Component, timing Timing) error {
+	problems := &configurationError{}
+
+	checkTiming("project", timing, problems)
+
+	known := make(map[*Component]bool, len(components))
+	names := make(map[string]bool, len(components))
+	for _, component := range components {
+		known[component] = true
+
+		if component.serviceName == "" {
+			problems.addf("component with empty service name")
+		} else if names[component.serviceName] {
+			problems.addf("duplicate service name %s", component.serviceName)
+		}
+		names[component.serviceName] = true
+
+		checkRunConfiguration(component, problems)
+		if component.probe != nil {
+			checkProbe(component.serviceName, *component.probe, problems)
+		}
+		if component.readinessProbe != nil {
+			checkProbe(component.serviceName+" readiness", *component.readinessProbe, problems)
+		}
+		if component.startupTimeout < 0 {
+			problems.addf("%s has a negative startup timeout", component.serviceName)
+		}
+		if component.timing != (Timing{}) {
+			checkTiming(component.serviceName, component.timing.withDefaults(timing), problems)
+		}
+	}
+
 	for _, component := range components {
+		dependencies := make(map[*Component]bool, len(component.dependencies))
 		for _, dependency := range component.dependencies {
-			for _, d := range dependency.dependencies {
-				if d == component {
-					return fmt.Errorf(
-						"cyclic dependency found: %s and %s",
-						component.serviceName, dependency.serviceName,
-					)
-				}
+			dependencies[dependency] = true
+			if !known[dependency] {
+				problems.addf(
+					"%s depends on %s which is not in the component list",
+					component.serviceName, dependency.serviceName,
+				)
 			}
 		}
+		checkDependencyPolicies(component, dependencies, problems)
+	}
+
+	checkCycles(components, known, problems)
+
+	if len(problems.problems) > 0 {
+		return problems
 	}
 	return nil
 }
+
+// checkRunConfiguration ensures a component has everything needed to be built and run.
+func checkRunConfiguration(component *Component, problems *configurationError) {
+	runConfig := component.runConfig
+
+	if runConfig.Image != "" {
+		if runConfig.BuildContextPath != "" || runConfig.DockerfilePath != "" {
+			problems.addf("%s has both an image and a build configuration", component.serviceName)
+		}
+		if _, err := reference.ParseNormalizedNamed(runConfig.Image); err != nil {
+			problems.addf("%s has an invalid image reference %q: %v", component.serviceName, runConfig.Image, err)
+		}
+		switch runConfig.PullPolicy {
+		case "", PullAlways, PullIfNotPresent, PullNever:
+		default:
+			problems.addf(
+				"%s has an unknown pull policy %q, expected one of %s, %s, %s",
+				component.serviceName, runConfig.PullPolicy, PullAlways, PullIfNotPresent, PullNever,
+			)
+		}
+	} else {
+		if runConfig.BuildContextPath == "" {
+			problems.addf("%s has an empty build context path", component.serviceName)
+		}
+		if runConfig.DockerfilePath == "" {
+			problems.addf("%s has an empty Dockerfile path", component.serviceName)
+		}
+		if runConfig.PullPolicy != "" {
+			problems.addf("%s has a pull policy but no image", component.serviceName)
+		}
+	}
+
+	for _, env := range runConfig.Env {
+		if strings.IndexByte(env, '=') <= 0 {
+			problems.addf("%s has a malformed env entry %q, expected KEY=VALUE", component.serviceName, env)
+		}
+	}
+
+	if signal := runConfig.StopSignal; signal != "" && !strings.HasPrefix(signal, "SIG") {
+		if _, err := strconv.Atoi(signal); err != nil {
+			problems.addf("%s has an invalid stop signal %q, expected e.g. SIGTERM or 15", component.serviceName, signal)
+		}
+	}
+	if runConfig.StopGracePeriod < 0 {
+		problems.addf("%s has a negative stop grace period", component.serviceName)
+	}
+}
+
+// checkProbe ensures a probe has the settings its type needs.
+func checkProbe(name string, probe Probe, problems *configurationError) {
+	switch probe.Type {
+	case "", HTTPProbe:
+		for _, status := range probe.ExpectedStatus {
+			if status < 100 || status > 599 {
+				problems.addf("%s probe expects an invalid HTTP status %d", name, status)
+			}
+		}
+		if probe.Path != "" && !strings.HasPrefix(probe.Path, "/") {
+			problems.addf("%s probe path %q doesn't start with /", name, probe.Path)
+		}
+		if probe.JSONEquals != "" && probe.JSONField == "" {
+			problems.addf("%s probe has an expected JSON value but no JSON field", name)
+		}
+	case TCPProbe, DockerProbe:
+	case ExecProbe:
+		if len(probe.Command) == 0 {
+			problems.addf("%s exec probe has no command", name)
+		}
+	default:
+		problems.addf(
+			"%s has an unknown probe type %q, expected one of %s, %s, %s, %s",
+			name, probe.Type, HTTPProbe, TCPProbe, ExecProbe, DockerProbe,
+		)
+	}
+
+	if probe.Port < 0 || probe.Port > 65535 {
+		problems.addf("%s probe has an invalid port %d", name, probe.Port)
+	}
+	if probe.Timeout < 0 || probe.Period < 0 {
+		problems.addf("%s probe has a negative timeout or period", name)
+	}
+	if probe.SuccessThreshold < 0 || probe.FailureThreshold < 0 {
+		problems.addf("%s probe has a negative threshold", name)
+	}
+}
+
+// checkDependencyPolicies ensures a component only has known policies for the components it depends on.
+func checkDependencyPolicies(component *Component, dependencies map[*Component]bool, problems *configurationError) {
+	for dependency, policy := range component.onRestart {
+		if !dependencies[dependency] {
+			problems.addf(
+				"%s has a restart policy for %s which is not one of its dependencies",
+				component.serviceName, dependency.serviceName,
+			)
+		}
+		switch policy {
+		case DependencyNone, DependencyRestart, DependencySignal:
+		default:
+			problems.addf(
+				"%s has an unknown restart policy %q for %s, expected one of %s, %s, %s",
+				component.serviceName, policy, dependency.serviceName, DependencyNone, DependencyRestart, DependencySignal,
+			)
+		}
+	}
+}
+
+// checkTiming ensures a timing is positive and lets a component be checked at least once before it's considered failed.
+func checkTiming(name string, timing Timing, problems *configurationError) {
+	if timing.Interval <= 0 || timing.FailureTimeout <= 0 || timing.GracePeriod < 0 {
+		problems.addf("%s has a non-positive interval or failure timeout, or a negative grace period", name)
+		return
+	}
+	if timing.FailureTimeout <= timing.Interval {
+		problems.addf(
+			"%s has a failure timeout of %s, which should be larger than its interval of %s",
+			name, timing.FailureTimeout, timing.Interval,
+		)
+	}
+}
+
+// checkCycles walks the dependency graph depth-first and reports every cyclic dependency with its full path.
+// Dependencies that are not known are not traversed.
+func checkCycles(components []*Component, known map[*Component]bool, problems *configurationError) {
+	const (
+		unvisited = iota
+		visiting  // on the current path
+		visited   // all dependencies checked
+	)
+	state := make(map[*Component]int, len(components))
+	var path []*Component
+
+	var visit func(component *Component)
+	visit = func(component *Component) {
+		state[component] = visiting
+		path = append(path, component)
+
+		for _, dependency := range component.dependencies {
+			if !known[dependency] {
+				continue
+			}
+			switch state[dependency] {
+			case unvisited:
+				visit(dependency)
+			case visiting:
+				// Found a back edge, the cycle is the part of the path starting at the dependency.
+				start := len(path) - 1
+				for path[start] != dependency {
+					start--
+				}
+				var cycle []string
+				for _, c := range path[start:] {
+					cycle = append(cycle, c.serviceName)
+				}
+				cycle = append(cycle, dependency.serviceName)
+				problems.addf("cyclic dependency found: %s", strings.Join(cycle, " -> "))
+			}
+		}
+
+		path = path[:len(path)-1]
+		state[component] = visited
+	}
+
+	for _, component := range components {
+		if state[component] == unvisited {
+			visit(component)
+		}
+	}
+}
@@ -1,33 +1,184 @@
 package internal
 
 import (
+	"strings"
 	"testing"
+	"time"
 )
 
-func TestCheckConfiguration(t *testing.T) {
-	a := &Component{
-		dependencies: []*Component{},
-	}
-
-	b := &Component{
-		dependencies: []*Component{a},
+func testComponent(name string, dependencies ...*Component) *Component {
+	return &Component{
+		serviceName: name,
+		runConfig: RunConfiguration{
+			DockerfilePath:   "Dockerfile",
+			BuildContextPath: "/" + name,
+		},
+		dependencies: dependencies,
 	}
+}
 
-	c := &Component{
-		dependencies: []*Component{b},
-	}
+func TestCheckConfiguration(t *testing.T) {
+	a := testComponent("a")
+	b := testComponent("b", a)
+	c := testComponent("c", b)
 
 	components := []*Component{a, b, c}
 
-	err := checkConfiguration(components)
+	err := checkConfiguration(components, defaultTiming)
 	if err != nil {
 		t.Fatal("Check failed but should have passed.")
 	}
 
 	b.dependencies = append(b.dependencies, c)
 
-	err = checkConfiguration(components)
+	err = checkConfiguration(components, defaultTiming)
 	if err == nil {
 		t.Fatal("Check should have failed.")
 	}
 }
+
+func TestCheckConfigurationLongCycle(t *testing.T) {
+	a := testComponent("a")
+	b := testComponent("b", a)
+	c := testComponent("c", b)
+	a.dependencies = append(a.dependencies, c)
+
+	err := checkConfiguration([]*Component{a, b, c}, defaultTiming)
+	if err == nil {
+		t.Fatal("Check should have failed.")
+	}
+	if !strings.Contains(err.Error(), "a -> c -> b -> a") {
+		t.Fatalf("Error doesn't report the cycle path: %v", err)
+	}
+}
+
+func TestCheckConfigurationAggregatesProblems(t *testing.T) {
+	outsider := testComponent("outsider")
+	a := testComponent("a", outsider)
+	a.runConfig.Env = []string{"GOOD=1", "BAD", "=empty"}
+	a.runConfig.StopSignal = "TERM"
+	a.runConfig.StopGracePeriod = -time.Second
+	duplicate := testComponent("a")
+	empty := testComponent("empty")
+	empty.runConfig = RunConfiguration{}
+	empty.readinessProbe = &Probe{Type: ExecProbe}
+	empty.startupTimeout = -time.Second
+
+	err := checkConfiguration([]*Component{a, duplicate, empty}, defaultTiming)
+	if err == nil {
+		t.Fatal("Check should have failed.")
+	}
+
+	expected := []string{
+		"duplicate service name a",
+		"a depends on outsider which is not in the component list",
+		`malformed env entry "BAD"`,
+		`malformed env entry "=empty"`,
+		`a has an invalid stop signal "TERM"`,
+		"a has a negative stop grace period",
+		"empty has an empty build context path",
+		"empty has an empty Dockerfile path",
+		"empty readiness exec probe has no command",
+		"empty has a negative startup timeout",
+	}
+	for _, problem := range expected {
+		if !strings.Contains(err.Error(), problem) {
+			t.Errorf("Error doesn't report %q:\n%v", problem, err)
+		}
+	}
+}
+
+func TestCheckConfigurationImages(t *testing.T) {
+	valid := &Component{serviceName: "valid", runConfig: RunConfiguration{Image: "postgres@sha256:" + strings.Repeat("a", 64)}}
+	both := testComponent("both")
+	both.runConfig.Image = "postgres:14"
+	invalid := &Component{serviceName: "invalid", runConfig: RunConfiguration{Image: "Not A Reference"}}
+	policy := &Component{serviceName: "policy", runConfig: RunConfiguration{Image: "postgres", PullPolicy: "sometimes"}}
+
+	if err := checkConfiguration([]*Component{valid}, defaultTiming); err != nil {
+		t.Fatalf("Check failed but should have passed: %v", err)
+	}
+
+	err := checkConfiguration([]*Component{valid, both, invalid, policy}, defaultTiming)
+	if err == nil {
+		t.Fatal("Check should have failed.")
+	}
+	expected := []string{
+		"both has both an image and a build configuration",
+		`invalid has an invalid image reference "Not A Reference"`,
+		`policy has an unknown pull policy "sometimes"`,
+	}
+	for _, problem := range expected {
+		if !strings.Contains(err.Error(), problem) {
+			t.Errorf("Error doesn't report %q:\n%v", problem, err)
+		}
+	}
+}
+
+func TestCheckConfigurationTiming(t *testing.T) {
+	a := testComponent("a")
+	b := testComponent("b")
+	b.timing = Timing{Interval: 5 * time.Second}
+	c := testComponent("c")
+	c.timing = Timing{GracePeriod: -time.Second}
+
+	if err := checkConfiguration([]*Component{a}, defaultTiming); err != nil {
+		t.Fatalf("Check failed but should have passed: %v", err)
+	}
+
+	err := checkConfiguration([]*Component{a, b, c}, Timing{Interval: time.Second, FailureTimeout: time.Second})
+	if err == nil {
+		t.Fatal("Check should have failed.")
+	}
+	expected := []string{
+		"project has a failure timeout of 1s, which should be larger than its interval of 1s",
+		"b has a failure timeout of 1s, which should be larger than its interval of 5s",
+		"c has a non-positive interval or failure timeout, or a negative grace period",
+	}
+	for _, problem := range expected {
+		if !strings.Contains(err.Error(), problem) {
+			t.Errorf("Error doesn't report %q:\n%v", problem, err)
+		}
+	}
+}
+
+func TestCheckConfigurationDependencyPolicies(t *testing.T) {
+	a := testComponent("a")
+	b := testComponent("b", a)
+	b.onRestart = map[*Component]DependencyPolicy{a: DependencyRestart}
+
+	if err := checkConfiguration([]*Component{a, b}, defaultTiming); err != nil {
+		t.Fatalf("Check failed but should have passed: %v", err)
+	}
+
+	c := testComponent("c", a)
+	c.onRestart = map[*Component]DependencyPolicy{a: "reload", b: DependencySignal}
+	err := checkConfiguration([]*Component{a, b, c}, defaultTiming)
+	if err == nil {
+		t.Fatal("Check should have failed.")
+	}
+	expected := []string{
+		`c has an unknown restart policy "reload" for a`,
+		"c has a restart policy for b which is not one of its dependencies",
+	}
+	for _, problem := range expected {
+		if !strings.Contains(err.Error(), problem) {
+			t.Errorf("Error doesn't report %q:\n%v", problem, err)
+		}
+	}
+}
+
+func TestTopologicalOrder(t *testing.T) {
+	a := testComponent("a")
+	b := testComponent("b", a)
+	c := testComponent("c", b, a)
+	d := testComponent("d")
+
+	var names []string
+	for _, component := range topologicalOrder([]*Component{c, d, b, a}) {
+		names = append(names, component.serviceName)
+	}
+	if order := strings.Join(names, ","); order != "a,b,c,d" {
+		t.Fatalf("Unexpected order %s.", order)
+	}
+}
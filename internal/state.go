@@ -0,0 +1,153 @@
+package internal
+
+import (
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"time"
+)
+
+type status int32
+
+// The statuses the Component may be in.
+const (
+	Unstarted        status = iota
+	Starting                // started but not ready to serve yet
+	Ready                   // passed its readiness probe
+	Failed                  // detected as failed and waiting to be restarted
+	CrashLoopBackOff        // failed repeatedly and waiting to be restarted
+	Restarting              // being relaunched after a failure
+	Stopped                 // stopped on purpose
+)
+
+// statusNames are the names of the statuses as shown to users.
+var statusNames = map[status]string{
+	Unstarted:        "unstarted",
+	Starting:         "starting",
+	Ready:            "ready",
+	Failed:           "failed",
+	CrashLoopBackOff: "crash-loop-backoff",
+	Restarting:       "restarting",
+	Stopped:          "stopped",
+}
+
+// String returns the name of the status.
+func (s status) String() string {
+	if name, ok := statusNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("status(%d)", int32(s))
+}
+
+// MarshalText encodes the status by its name.
+func (s status) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText decodes a status from its name.
+func (s *status) UnmarshalText(text []byte) error {
+	for candidate, name := range statusNames {
+		if name == string(text) {
+			*s = candidate
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown status %s", text)
+}
+
+// transitions are the legal changes of status. Any status can change to Stopped.
+var transitions = map[status][]status{
+	Unstarted:        {Starting},
+	Starting:         {Ready, Failed, Restarting},
+	Ready:            {Failed, Restarting},
+	Failed:           {CrashLoopBackOff, Restarting},
+	CrashLoopBackOff: {Restarting},
+	Restarting:       {Starting, Failed},
+	Stopped:          {Starting},
+}
+
+// legalTransition checks whether a component may change from one status to another.
+func legalTransition(from status, to status) bool {
+	if to == Stopped {
+		return from != Stopped
+	}
+	for _, next := range transitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Transition is a change of the status of a component.
+type Transition struct {
+	Component string    `json:"component"`
+	From      status    `json:"from"`
+	To        status    `json:"to"`
+	At        time.Time `json:"at"`
+}
+
+// subscriberBuffer is the number of transitions a subscriber may fall behind before it misses some.
+const subscriberBuffer = 64
+
+// transition changes the status of a component and publishes the change to the subscribers.
+// If any expected statuses are given, the component has to be in one of them, which makes it possible to
+// atomically check and change the status when several goroutines may handle the same component.
+func (mw *Millwright) transition(component *Component, to status, expected ...status) error {
+	component.mu.Lock()
+	defer component.mu.Unlock()
+
+	from := component.status
+	if len(expected) > 0 {
+		found := false
+		for _, s := range expected {
+			found = found || s == from
+		}
+		if !found {
+			return fmt.Errorf("%s is %s, expected %v", component.serviceName, from, expected)
+		}
+	}
+	if !legalTransition(from, to) {
+		return fmt.Errorf("illegal transition of %s from %s to %s", component.serviceName, from, to)
+	}
+
+	component.status = to
+	log.Debugf("%s changed from %s to %s.", component.serviceName, from, to)
+
+	// Publishing while the component is locked keeps the transitions of each component in order.
+	mw.publish(Transition{Component: component.serviceName, From: from, To: to, At: time.Now()})
+	return nil
+}
+
+// Subscribe returns a channel that receives every transition of the components, and a function that ends
+// the subscription. A subscriber that falls more than a few dozen transitions behind misses the ones that follow.
+// The control API streams the transitions to its clients this way.
+func (mw *Millwright) Subscribe() (<-chan Transition, func()) {
+	mw.mu.Lock()
+	defer mw.mu.Unlock()
+
+	ch := make(chan Transition, subscriberBuffer)
+	mw.subscribers[ch] = true
+	return ch, func() {
+		mw.mu.Lock()
+		defer mw.mu.Unlock()
+
+		if mw.subscribers[ch] {
+			delete(mw.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// publish sends a transition to the subscribers without waiting for them.
+func (mw *Millwright) publish(transition Transition) {
+	mw.mu.Lock()
+	defer mw.mu.Unlock()
+
+	for ch := range mw.subscribers {
+		select {
+		case ch <- transition:
+		default:
+			log.Warnf("A subscriber missed the transition of %s to %s.", transition.Component, transition.To)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package internal
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLegalTransition(t *testing.T) {
+	cases := []struct {
+		from  status
+		to    status
+		legal bool
+	}{
+		{Unstarted, Starting, true},
+		{Starting, Ready, true},
+		{Ready, Failed, true},
+		{Failed, Restarting, true},
+		{Failed, CrashLoopBackOff, true},
+		{CrashLoopBackOff, Restarting, true},
+		{Restarting, Starting, true},
+		{Ready, Stopped, true},
+		{Stopped, Starting, true},
+		{Unstarted, Ready, false},
+		{Failed, Ready, false},
+		{Ready, Starting, false},
+		{Failed, Failed, false},
+		{Stopped, Stopped, false},
+	}
+	for _, c := range cases {
+		if legal := legalTransition(c.from, c.to); legal != c.legal {
+			t.Errorf("Transition from %s to %s is legal: %t, expected %t.", c.from, c.to, legal, c.legal)
+		}
+	}
+}
+
+func TestTransitionPublishesChanges(t *testing.T) {
+	mw := NewMillwright(newFakeBackend(), Options{})
+	component := testComponent("ingestion")
+
+	transitions, unsubscribe := mw.Subscribe()
+	defer unsubscribe()
+
+	if err := mw.transition(component, Ready); err == nil {
+		t.Fatal("Illegal transition was allowed.")
+	}
+	if err := mw.transition(component, Starting); err != nil {
+		t.Fatal(err)
+	}
+	if err := mw.transition(component, Failed, Ready); err == nil {
+		t.Fatal("Transition from an unexpected status was allowed.")
+	}
+	if err := mw.transition(component, Failed, Starting, Ready); err != nil {
+		t.Fatal(err)
+	}
+	if component.status != Failed {
+		t.Fatalf("Component is %s, expected %s.", component.status, Failed)
+	}
+
+	for _, expected := range []Transition{{From: Unstarted, To: Starting}, {From: Starting, To: Failed}} {
+		select {
+		case transition := <-transitions:
+			if transition.Component != "ingestion" || transition.From != expected.From || transition.To != expected.To {
+				t.Errorf("Unexpected transition %+v, expected from %s to %s.", transition, expected.From, expected.To)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Transition from %s to %s wasn't published.", expected.From, expected.To)
+		}
+	}
+	select {
+	case transition := <-transitions:
+		t.Errorf("Rejected transition %+v was published.", transition)
+	default:
+	}
+
+	unsubscribe()
+	if _, ok := <-transitions; ok {
+		t.Error("Subscription wasn't closed.")
+	}
+}
@@ -0,0 +1,190 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Health of a component as reported by Status.
+const (
+	HealthHealthy   = "healthy"
+	HealthUnhealthy = "unhealthy"
+	HealthStarting  = "starting"
+	HealthIgnored   = "ignored"
+	HealthUnknown   = "unknown"
+)
+
+// ComponentStatus is the state of a configured component and its container.
+type ComponentStatus struct {
+	Name              string     `json:"name"`
+	ContainerID       string     `json:"container_id,omitempty"`
+	Image             string     `json:"image,omitempty"`
+	State             string     `json:"state"` // status of the component, or of its container if it isn't monitored
+	StartedAt         *time.Time `json:"started_at,omitempty"`
+	Uptime            string     `json:"uptime,omitempty"`
+	Restarts          int        `json:"restarts"`
+	LastHeartbeat     *time.Time `json:"last_heartbeat,omitempty"`     // time of the last successful heartbeat
+	IntrospectionPort string     `json:"introspection_port,omitempty"` // host port the introspection port is bound to
+	Health            string     `json:"health"`                       // one of the Health constants
+	Missing           bool       `json:"missing,omitempty"`            // configured but has no container
+}
+
+// UnknownContainer is a container labeled by millwright whose component isn't part of the configuration.
+type UnknownContainer struct {
+	ContainerID string `json:"container_id"`
+	Name        string `json:"name"`
+	Image       string `json:"image"`
+	State       string `json:"state"`
+}
+
+// ProjectStatus is the state of all the components of a project.
+type ProjectStatus struct {
+	Components []ComponentStatus  `json:"components"`
+	Unknown    []UnknownContainer `json:"unknown_containers"`
+	Monitored  bool               `json:"monitored"` // reported by the running millwright of the project
+	Paused     bool               `json:"paused"`    // the running millwright has paused monitoring the components
+}
+
+// CheckStatus reports the state of the components of the given project.
+// The components are sent a heartbeat to find out whether they are healthy.
+func CheckStatus(ctx context.Context, project *Project) (*ProjectStatus, error) {
+	mw, ctx, err := newProjectMillwright(ctx, project, Options{})
+	if err != nil {
+		return nil, err
+	}
+	return mw.Status(ctx)
+}
+
+// Status reports the state of the components and their containers, along with the containers labeled by millwright
+// that don't belong to any of the components.
+// Components that aren't monitored by this millwright are sent a heartbeat to find out whether they are healthy.
+func (mw *Millwright) Status(ctx context.Context) (*ProjectStatus, error) {
+	containers, err := mw.backend.ListContainers(ctx, ListOptions{
+		Label: projectFilter(ctx),
+		All:   true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	byComponent, unknown := mw.containersByComponent(containers)
+
+	status := &ProjectStatus{Components: []ComponentStatus{}, Unknown: []UnknownContainer{}}
+	for _, component := range mw.currentComponents() {
+		container, ok := byComponent[component.serviceName]
+		if !ok {
+			status.Components = append(status.Components, ComponentStatus{
+				Name:     component.serviceName,
+				State:    "missing",
+				Restarts: component.totalRestarts(),
+				Health:   HealthUnknown,
+				Missing:  true,
+			})
+			continue
+		}
+
+		componentStatus, err := mw.componentStatus(ctx, component, container.ID)
+		if err != nil {
+			return nil, fmt.Errorf("can't get the status of %s: %v", component.serviceName, err)
+		}
+		status.Components = append(status.Components, componentStatus)
+	}
+
+	for _, container := range unknown {
+		status.Unknown = append(status.Unknown, UnknownContainer{
+			ContainerID: container.ID,
+			Name:        container.Name,
+			Image:       container.Image,
+			State:       containerState(container),
+		})
+	}
+	return status, nil
+}
+
+// componentStatus reports the state of a component running in the container with the given ID.
+func (mw *Millwright) componentStatus(ctx context.Context, component *Component, id string) (ComponentStatus, error) {
+	container, err := mw.backend.InspectContainer(ctx, id)
+	if err != nil {
+		return ComponentStatus{}, err
+	}
+
+	status := ComponentStatus{
+		Name:              component.serviceName,
+		ContainerID:       container.ID,
+		Image:             container.Image,
+		IntrospectionPort: container.HostPorts[ctx.Value(introspectionPortKey).(int)],
+	}
+	if container.Running && !container.StartedAt.IsZero() {
+		startedAt := container.StartedAt
+		status.StartedAt = &startedAt
+		status.Uptime = time.Since(startedAt).Round(time.Second).String()
+	}
+
+	var heartbeat time.Time
+	monitored, _ := component.container()
+	if current := component.currentStatus(); monitored == id && current != Unstarted {
+		status.State = current.String()
+		status.Restarts = component.totalRestarts()
+		status.Health = statusHealth(current)
+		heartbeat = component.lastHeartbeat()
+	} else {
+		status.State = containerState(container)
+		status.Restarts, _ = strconv.Atoi(container.Labels[restartsLabel])
+		status.Health, heartbeat = mw.probeHealth(ctx, component, container)
+	}
+	if component.ignore {
+		status.Health = HealthIgnored
+	}
+
+	if !heartbeat.IsZero() {
+		status.LastHeartbeat = &heartbeat
+	}
+	return status, nil
+}
+
+// probeHealth sends a heartbeat to a component whose container isn't the one monitored by this millwright.
+// The heartbeat is sent to a detached copy of the component, since the monitored component may be relaunched in
+// the meantime. It returns the health of the component and the time of the heartbeat if it succeeded.
+func (mw *Millwright) probeHealth(ctx context.Context, component *Component, container *Container) (string, time.Time) {
+	if component.ignore || !container.Running {
+		return HealthUnknown, time.Time{}
+	}
+
+	probed := component.detached()
+	probed.setContainer(container.ID)
+	probed.setHostPorts(container.HostPorts)
+	probe := probed.livenessProbe(ctx.Value(introspectionPortKey).(int), mw.timingOf(probed).Interval)
+	ok := mw.SendHeartbeat(ctx, probed, probe)
+	probed.recordLiveness(ok, probe, 0)
+	if ok {
+		return HealthHealthy, probed.lastHeartbeat()
+	}
+	return HealthUnhealthy, time.Time{}
+}
+
+// statusHealth returns the health of a monitored component with the given status.
+func statusHealth(status status) string {
+	switch status {
+	case Ready:
+		return HealthHealthy
+	case Starting, Restarting:
+		return HealthStarting
+	case Failed, CrashLoopBackOff:
+		return HealthUnhealthy
+	default:
+		return HealthUnknown
+	}
+}
+
+// containerState describes the state of a container that isn't monitored.
+func containerState(container *Container) string {
+	switch {
+	case container.Running:
+		return "running"
+	case container.StartedAt.IsZero():
+		return "created"
+	default:
+		return fmt.Sprintf("exited (%d)", container.ExitCode)
+	}
+}
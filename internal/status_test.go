@@ -0,0 +1,111 @@
+package internal
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStatusReportsComponentsAndUnknownContainers(t *testing.T) {
+	backend := newFakeBackend()
+	defer backend.close()
+	mw := testMillwright(t, backend)
+	ctx := testContext(t)
+
+	if err := mw.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	cpu := mw.components[0]
+	cpu.recordRestart(time.Now())
+	if _, err := mw.relaunchComponent(ctx, cpu); err != nil {
+		t.Fatal(err)
+	}
+	// A container of a component that has been removed from the config.
+	if _, err := backend.CreateContainer(ctx, ContainerSpec{
+		Name:   ContainerName(testProject, "handler_old"),
+		Image:  backend.container("ingestion").Image,
+		Labels: map[string]string{"used-by": "millwright", projectLabel: testProject, componentLabel: "handler_old"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := backend.RemoveContainer(ctx, backend.container("handler_load").ID); err != nil {
+		t.Fatal(err)
+	}
+
+	// A millwright that doesn't monitor the components, like the one of `mw status`.
+	observer := NewMillwright(backend, Options{})
+	observer.components = []*Component{
+		testComponent("handler_cpu"), testComponent("handler_load"), testComponent("ingestion"), testComponent("source"),
+	}
+	observer.components[3].ignore = true
+	status, err := observer.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	byName := map[string]ComponentStatus{}
+	for _, component := range status.Components {
+		byName[component.Name] = component
+	}
+	if cpu := byName["handler_cpu"]; cpu.State != "running" || cpu.Health != HealthHealthy ||
+		cpu.LastHeartbeat == nil || cpu.StartedAt == nil || cpu.IntrospectionPort == "" || cpu.Restarts != 1 {
+		t.Errorf("Unexpected status of handler_cpu: %+v.", cpu)
+	}
+	if load := byName["handler_load"]; !load.Missing || load.ContainerID != "" {
+		t.Errorf("handler_load has no container but its status is %+v.", load)
+	}
+	if source := byName["source"]; source.Health != HealthIgnored {
+		t.Errorf("source is ignored but its health is %s.", source.Health)
+	}
+	if len(status.Unknown) != 1 || status.Unknown[0].Name != "test-handler_old" || status.Unknown[0].State != "created" {
+		t.Errorf("Unexpected unknown containers: %+v.", status.Unknown)
+	}
+}
+
+func TestStatusOfMonitoredComponents(t *testing.T) {
+	backend := newFakeBackend()
+	defer backend.close()
+	mw := testMillwright(t, backend)
+	ctx := testContext(t)
+
+	if err := mw.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	ingestion := mw.components[2]
+	mw.checkReadiness(ctx, ingestion)
+	if err := mw.transition(mw.components[0], Failed); err != nil {
+		t.Fatal(err)
+	}
+	ingestion.recordRestart(time.Now())
+
+	status, err := mw.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cpu := status.Components[0]; cpu.State != Failed.String() || cpu.Health != HealthUnhealthy {
+		t.Errorf("Unexpected status of handler_cpu: %+v.", cpu)
+	}
+	if status := status.Components[2]; status.State != Ready.String() || status.Health != HealthHealthy ||
+		status.Restarts != 1 {
+		t.Errorf("Unexpected status of ingestion: %+v.", status)
+	}
+}
+
+func TestStatusLeavesMonitoredComponentsAlone(t *testing.T) {
+	backend := newFakeBackend()
+	defer backend.close()
+	mw := testMillwright(t, backend)
+	ctx := testContext(t)
+
+	if err := mw.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// The component is being relaunched, so its container isn't the monitored one yet.
+	cpu := mw.components[0]
+	cpu.setContainer("relaunching")
+	if _, err := mw.Status(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if id, _ := cpu.container(); id != "relaunching" || !cpu.lastHeartbeat().IsZero() {
+		t.Errorf("Querying the status changed the monitored component, its container is %s.", id)
+	}
+}
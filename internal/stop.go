@@ -0,0 +1,119 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+)
+
+// StopComponents stops the running containers of the components of the given project with the given names,
+// or of all of its components if no names are given.
+// Images and the network are left in place, so that the project can be started again quickly.
+func StopComponents(ctx context.Context, project *Project, names []string) error {
+	mw, ctx, err := newProjectMillwright(ctx, project, Options{})
+	if err != nil {
+		return err
+	}
+	return mw.Stop(ctx, names)
+}
+
+// Stop stops the containers of the components with the given names, or of all components if no names are given.
+// Dependents are stopped before their dependencies. Each component is sent its stop signal and is killed if it
+// doesn't exit within its stop grace period. Stopped containers are kept, and the components are marked as Stopped
+// so that their exits aren't handled as failures.
+func (mw *Millwright) Stop(ctx context.Context, names []string) error {
+	// Reloads may be swapping the components.
+	mw.changing.Lock()
+	defer mw.changing.Unlock()
+
+	selected, err := mw.selectComponents(names)
+	if err != nil {
+		return err
+	}
+
+	order := topologicalOrder(mw.currentComponents())
+	for i := len(order) - 1; i >= 0; i-- {
+		component := order[i]
+		if !selected[component] {
+			continue
+		}
+		if err := mw.stopComponent(ctx, component); err != nil {
+			return fmt.Errorf("can't stop %s: %v", component.serviceName, err)
+		}
+	}
+	return nil
+}
+
+// Kill removes the containers of the components with the given names by force, and keeps the components stopped.
+// The components are marked as Stopped first, so that the removal isn't taken for a crash.
+func (mw *Millwright) Kill(ctx context.Context, names []string) error {
+	// Reloads may be swapping the components.
+	mw.changing.Lock()
+	defer mw.changing.Unlock()
+
+	selected, err := mw.selectComponents(names)
+	if err != nil {
+		return err
+	}
+
+	for _, component := range mw.currentComponents() {
+		if !selected[component] {
+			continue
+		}
+		id, err := findComponent(ctx, mw.backend, ctx.Value(projectNameKey).(string), component.serviceName, false)
+		if err != nil {
+			return err
+		}
+		_ = mw.transition(component, Stopped)
+		log.Infof("Killing %s.", component.serviceName)
+		if err := mw.backend.RemoveContainer(ctx, id); err != nil {
+			return fmt.Errorf("can't kill %s: %v", component.serviceName, err)
+		}
+	}
+	return nil
+}
+
+// selectComponents returns the components with the given names, or all components if no names are given.
+func (mw *Millwright) selectComponents(names []string) (map[*Component]bool, error) {
+	byName := make(map[string]*Component, len(mw.currentComponents()))
+	selected := make(map[*Component]bool, len(mw.currentComponents()))
+	for _, component := range mw.currentComponents() {
+		byName[component.serviceName] = component
+		if len(names) == 0 {
+			selected[component] = true
+		}
+	}
+
+	for _, name := range names {
+		component, ok := byName[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown component %s", name)
+		}
+		selected[component] = true
+	}
+	return selected, nil
+}
+
+// stopComponent stops the container of a component if it's running.
+func (mw *Millwright) stopComponent(ctx context.Context, component *Component) error {
+	// Mark it first, so that the exit of the container isn't taken for a failure.
+	_ = mw.transition(component, Stopped)
+
+	id, ok := mw.getComponent(ctx, component)
+	if !ok {
+		log.Infof("%s is not running.", component.serviceName)
+		return nil
+	}
+
+	signal := component.runConfig.StopSignal
+	if signal == "" {
+		signal = defaultStopSignal
+	}
+	grace := component.runConfig.StopGracePeriod
+	if grace == 0 {
+		grace = defaultStopGracePeriod
+	}
+
+	log.Infof("Stopping %s with %s.", component.serviceName, signal)
+	return mw.backend.StopContainer(ctx, id, signal, grace)
+}
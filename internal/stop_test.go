@@ -0,0 +1,80 @@
+package internal
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStopStopsDependentsFirst(t *testing.T) {
+	backend := newFakeBackend()
+	defer backend.close()
+	mw := testMillwright(t, backend)
+	ctx := testContext(t)
+
+	if err := mw.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := mw.Stop(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"test-handler_load:SIGTERM", "test-handler_cpu:SIGTERM", "test-ingestion:SIGTERM", "test-source:SIGTERM",
+	}
+	if !reflect.DeepEqual(backend.stops, expected) {
+		t.Fatalf("Stopped %v, expected %v.", backend.stops, expected)
+	}
+	for _, component := range mw.components {
+		if status := component.currentStatus(); status != Stopped {
+			t.Errorf("%s is %s, expected %s.", component.serviceName, status, Stopped)
+		}
+	}
+
+	// Stopping again is a no-op.
+	if err := mw.Stop(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(backend.stops) != len(expected) {
+		t.Fatalf("Stopped containers that weren't running: %v.", backend.stops)
+	}
+}
+
+func TestStopLeavesStoppedComponentsDown(t *testing.T) {
+	backend := newFakeBackend()
+	defer backend.close()
+	mw := testMillwright(t, backend)
+	mw.timing = fastTiming
+	ctx := testContext(t)
+
+	ingestion := mw.components[2]
+	ingestion.runConfig.StopSignal = "SIGINT"
+
+	if err := mw.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer reconcile(ctx, mw)()
+
+	if err := mw.Stop(ctx, []string{"ingestion"}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(backend.stops, []string{"test-ingestion:SIGINT"}) {
+		t.Fatalf("Stopped %v, expected only ingestion with SIGINT.", backend.stops)
+	}
+
+	// Give Reconcile a chance to handle the exit.
+	time.Sleep(10 * fastTiming.Interval)
+	if status := ingestion.currentStatus(); status != Stopped {
+		t.Fatalf("ingestion is %s, expected %s.", status, Stopped)
+	}
+	if container := backend.container("ingestion"); container == nil || container.Running {
+		t.Fatal("Stopped container wasn't kept, or was relaunched.")
+	}
+	if container := backend.container("handler_cpu"); container == nil || !container.Running {
+		t.Fatal("Component that wasn't stopped is down.")
+	}
+
+	if err := mw.Stop(ctx, []string{"unknown"}); err == nil {
+		t.Fatal("Stopping an unknown component should have failed.")
+	}
+}